@@ -2,12 +2,21 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"github.com/ahirata/notifyme/internal/pkg/config"
+	"github.com/ahirata/notifyme/internal/pkg/history"
+	"github.com/ahirata/notifyme/internal/pkg/idle"
 	"github.com/ahirata/notifyme/internal/pkg/server"
+	"github.com/ahirata/notifyme/internal/pkg/ui"
 	"github.com/gotk3/gotk3/gtk"
+	"os"
+	"time"
 )
 
 func main() {
 	kill := flag.Bool("k", false, "kill notifyme")
+	configPath := flag.String("c", config.DefaultPath(), "configuration file")
+	replace := flag.Bool("replace", false, "replace the running notification server")
 	flag.Parse()
 
 	if *kill {
@@ -16,11 +25,37 @@ func main() {
 		return
 	}
 
+	configuration, err := config.Load(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Invalid configuration:")
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
 	gtk.Init(nil)
 
-	server := notifyme.ServerNew()
+	notificationHistory, err := history.HistoryNew(history.DefaultDir(), configuration.HistoryMaxEntries, configuration.HistoryMaxAge)
+	if err != nil {
+		fmt.Println("History disabled:", err)
+	}
+
+	server := notifyme.ServerNew(ui.RendererNew(), notificationHistory)
+	server.Configure(configuration)
+	config.Watch(*configPath, 2*time.Second, server.Configure)
+	server.WatchDoNotDisturb(30 * time.Second)
+
+	if source, err := idle.Detect(configuration.IdleSource, configuration.IdleThreshold); err != nil {
+		fmt.Println("Idle detection disabled:", err)
+	} else if source != nil {
+		idle.Watch(source, 5*time.Second, server.SetIdle)
+	}
 
-	go server.Start()
+	go func() {
+		if err := server.Start(*replace); err != nil {
+			fmt.Fprintln(os.Stderr, "Unable to start the server:", err)
+			os.Exit(1)
+		}
+	}()
 
 	gtk.Main()
 }
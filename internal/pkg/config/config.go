@@ -0,0 +1,449 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ahirata/notifyme/internal/pkg/dnd"
+	"github.com/ahirata/notifyme/internal/pkg/idle"
+	"github.com/ahirata/notifyme/internal/pkg/layout"
+	"github.com/ahirata/notifyme/internal/pkg/rules"
+	"github.com/ahirata/notifyme/pkg/notifyme/schema"
+)
+
+const (
+	generalSection = "general"
+	popupSection   = "popup"
+	historySection = "history"
+	rateSection    = "rate-limit"
+	dndSection     = "do-not-disturb"
+	ruleSection    = "rule"
+	quietSection   = "quiet-hours"
+)
+
+// How notifications are grouped into stacks
+const (
+	// GroupNone shows every notification in its own popup
+	GroupNone = "none"
+	// GroupApp stacks the notifications of the same application, told by their desktop entry or app name
+	GroupApp = "app"
+)
+
+// What happens to the notifications over the rate limits
+const (
+	// ExcessDrop discards them
+	ExcessDrop = "drop"
+	// ExcessSummarize shows how many were suppressed in a single popup, without recording them so that a flood
+	// does not push the other notifications out of the history
+	ExcessSummarize = "summarize"
+	// ExcessHistory records them to the history only
+	ExcessHistory = "history"
+)
+
+// SupportedCapabilities are the capabilities the server is able to honor
+var SupportedCapabilities = []string{"body", "actions", "body-hyperlinks", "body-markup", "icon-static", "persistence"}
+
+// Config holds the settings read from the configuration file
+type Config struct {
+	// Timeout is used for notifications that leave the expiration up to the server, in milliseconds
+	Timeout int32
+	// ResumeTimeout is the least time left to a notification when the pointer leaves its popup, in milliseconds
+	ResumeTimeout int32
+	// IdleSource tells how to detect that the user is away, which freezes the expirations
+	IdleSource string
+	// IdleThreshold is the time without input after which the user is away, for the sources that need one
+	IdleThreshold time.Duration
+	// Capabilities are returned by GetCapabilities
+	Capabilities []string
+
+	// Monitor chooses the monitor the popups are shown on
+	Monitor layout.MonitorPolicy
+	// Anchor is where the popups are stacked from, and Stacking where the new ones go
+	Anchor   layout.Anchor
+	Stacking layout.Order
+	// OffsetX and OffsetY are the distances from the edges of the workarea, and Spacing between popups
+	OffsetX       int
+	OffsetY       int
+	Spacing       int
+	IconSize      int
+	MaxWidthChars int
+	// Mirror lists the urgencies of the notifications shown on every monitor
+	Mirror []schema.Urgency
+	// GroupBy tells which notifications are stacked into a single expandable popup: none or app
+	GroupBy string
+	// DedupWindow is the time during which identical notifications are merged into a single popup. Zero disables it
+	DedupWindow time.Duration
+	// MaxVisible limits the popups on screen, queueing the others. Zero means no limit
+	MaxVisible int
+
+	HistoryMaxEntries int
+	HistoryMaxAge     time.Duration
+
+	// AppRate and SenderRate are the notifications per second allowed from each app and each sender,
+	// after a burst of AppBurst and SenderBurst. Zero disables the limit
+	AppRate     float64
+	AppBurst    int
+	SenderRate  float64
+	SenderBurst int
+	// Excess is what happens to the notifications over the limits: drop, summarize or history
+	Excess string
+
+	// DNDExceptions are the notifications shown even while do not disturb is active
+	DNDExceptions dnd.Exceptions
+	// QuietHours are the periods during which do not disturb is active, unless turned off
+	QuietHours []*dnd.Schedule
+
+	// Rules change the matching notifications, in order
+	Rules []*rules.Rule
+}
+
+// Default returns the configuration used when there is no configuration file
+func Default() *Config {
+	return &Config{
+		Timeout:           10000,
+		ResumeTimeout:     2000,
+		IdleSource:        idle.Auto,
+		IdleThreshold:     5 * time.Minute,
+		Capabilities:      []string{"body", "actions", "body-hyperlinks", "body-markup"},
+		Monitor:           layout.MonitorPolicy{layout.PrimaryMonitor},
+		Anchor:            layout.BottomRight,
+		Stacking:          layout.NewestFarthest,
+		OffsetX:           10,
+		OffsetY:           10,
+		Spacing:           10,
+		IconSize:          64,
+		MaxWidthChars:     45,
+		Mirror:            []schema.Urgency{},
+		GroupBy:           GroupNone,
+		DedupWindow:       0,
+		MaxVisible:        0,
+		HistoryMaxEntries: 1000,
+		HistoryMaxAge:     30 * 24 * time.Hour,
+		AppRate:           0,
+		AppBurst:          20,
+		SenderRate:        0,
+		SenderBurst:       20,
+		Excess:            ExcessSummarize,
+		DNDExceptions:     dnd.Exceptions{Urgencies: []schema.Urgency{schema.Critical}, Apps: []string{}},
+	}
+}
+
+// Mirrors returns true if notifications of the given urgency are shown on every monitor
+func (config *Config) Mirrors(urgency schema.Urgency) bool {
+	for _, mirrored := range config.Mirror {
+		if mirrored == urgency {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultPath returns $XDG_CONFIG_HOME/notifyme/config, falling back to ~/.config/notifyme/config
+func DefaultPath() string {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(os.Getenv("HOME"), ".config")
+	}
+	return filepath.Join(configHome, "notifyme", "config")
+}
+
+// Load reads the configuration file at path. A missing file results in the default configuration
+func Load(path string) (*Config, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return Default(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return Parse(file, path)
+}
+
+// Parse reads a configuration from reader, using name in the error messages. Unset settings keep their defaults
+func Parse(reader io.Reader, name string) (*Config, error) {
+	sections, err := parse(reader, name)
+	if err != nil {
+		return nil, err
+	}
+
+	config := Default()
+	var errs Errors
+	for _, section := range sections {
+		if section.name == ruleSection {
+			rule, ruleErrs := parseRule(section, name)
+			config.Rules = append(config.Rules, rule)
+			errs = append(errs, ruleErrs...)
+			continue
+		}
+		if section.name == quietSection {
+			schedule, scheduleErrs := parseSchedule(section, name)
+			config.QuietHours = append(config.QuietHours, schedule)
+			errs = append(errs, scheduleErrs...)
+			continue
+		}
+
+		fields, known := fieldsBySection[section.name]
+		if !known {
+			errs = append(errs, fmt.Errorf("%s:%d: unknown section [%s]", name, section.line, section.name))
+			continue
+		}
+		for _, setting := range section.settings {
+			set, known := fields[setting.key]
+			if !known {
+				errs = append(errs, fmt.Errorf("%s:%d: unknown key %q in [%s], expected one of: %s", name, setting.line, setting.key, section.name, keys(fields)))
+				continue
+			}
+			if err := set(config, setting.value); err != nil {
+				errs = append(errs, fmt.Errorf("%s:%d: invalid %s: %v", name, setting.line, setting.key, err))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return config, nil
+}
+
+// parseRule reads a "[rule name]" section
+func parseRule(section *section, name string) (*rules.Rule, Errors) {
+	var errs Errors
+	if section.argument == "" {
+		errs = append(errs, fmt.Errorf("%s:%d: rules need a name, as in [rule name]", name, section.line))
+	}
+
+	rule := rules.RuleNew(section.argument)
+	for _, setting := range section.settings {
+		if err := rule.Set(setting.key, setting.value); err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %v", name, setting.line, err))
+		}
+	}
+	return rule, errs
+}
+
+// parseSchedule reads a "[quiet-hours name]" section
+func parseSchedule(section *section, name string) (*dnd.Schedule, Errors) {
+	var errs Errors
+	if section.argument == "" {
+		errs = append(errs, fmt.Errorf("%s:%d: quiet hours need a name, as in [quiet-hours night]", name, section.line))
+	}
+
+	schedule := dnd.ScheduleNew(section.argument)
+	for _, setting := range section.settings {
+		if err := schedule.Set(setting.key, setting.value); err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %v", name, setting.line, err))
+		}
+	}
+	return schedule, errs
+}
+
+// field parses and validates a value into the configuration
+type field func(config *Config, value string) error
+
+var fieldsBySection = map[string]map[string]field{
+	generalSection: {
+		"timeout":        intField(0, 24*60*60*1000, func(config *Config, value int) { config.Timeout = int32(value) }),
+		"resume-timeout": intField(0, 24*60*60*1000, func(config *Config, value int) { config.ResumeTimeout = int32(value) }),
+		"idle-source": func(config *Config, value string) error {
+			if !contains(idle.Sources, value) {
+				return fmt.Errorf("unknown idle source %q, expected one of: %s", value, strings.Join(idle.Sources, ", "))
+			}
+			config.IdleSource = value
+			return nil
+		},
+		"idle-threshold": durationField(func(config *Config, value time.Duration) { config.IdleThreshold = value }),
+		"capabilities": func(config *Config, value string) error {
+			capabilities, err := capabilitiesValue(value)
+			config.Capabilities = capabilities
+			return err
+		},
+	},
+	popupSection: {
+		"monitor": func(config *Config, value string) error {
+			policy, err := layout.ParseMonitorPolicy(value)
+			config.Monitor = policy
+			return err
+		},
+		"anchor": func(config *Config, value string) error {
+			anchor, err := layout.ParseAnchor(value)
+			config.Anchor = anchor
+			return err
+		},
+		"stacking": func(config *Config, value string) error {
+			order, err := layout.ParseOrder(value)
+			config.Stacking = order
+			return err
+		},
+		"mirror": func(config *Config, value string) error {
+			urgencies, err := urgenciesValue(value)
+			config.Mirror = urgencies
+			return err
+		},
+		"group-by": func(config *Config, value string) error {
+			if value != GroupNone && value != GroupApp {
+				return fmt.Errorf("%q is not one of: %s, %s", value, GroupNone, GroupApp)
+			}
+			config.GroupBy = value
+			return nil
+		},
+		"spacing":         intField(0, 10000, func(config *Config, value int) { config.Spacing = value }),
+		"offset-x":        intField(0, 10000, func(config *Config, value int) { config.OffsetX = value }),
+		"offset-y":        intField(0, 10000, func(config *Config, value int) { config.OffsetY = value }),
+		"icon-size":       intField(8, 512, func(config *Config, value int) { config.IconSize = value }),
+		"max-width-chars": intField(1, 1000, func(config *Config, value int) { config.MaxWidthChars = value }),
+		"dedup-window":    durationField(func(config *Config, value time.Duration) { config.DedupWindow = value }),
+		"max-visible":     intField(0, 1000, func(config *Config, value int) { config.MaxVisible = value }),
+	},
+	historySection: {
+		"max-entries": intField(0, 1000000, func(config *Config, value int) { config.HistoryMaxEntries = value }),
+		"max-age":     durationField(func(config *Config, value time.Duration) { config.HistoryMaxAge = value }),
+	},
+	rateSection: {
+		"app-rate":     floatField(0, 1000, func(config *Config, value float64) { config.AppRate = value }),
+		"app-burst":    intField(1, 100000, func(config *Config, value int) { config.AppBurst = value }),
+		"sender-rate":  floatField(0, 1000, func(config *Config, value float64) { config.SenderRate = value }),
+		"sender-burst": intField(1, 100000, func(config *Config, value int) { config.SenderBurst = value }),
+		"excess": func(config *Config, value string) error {
+			excess := []string{ExcessDrop, ExcessSummarize, ExcessHistory}
+			if !contains(excess, value) {
+				return fmt.Errorf("%q is not one of: %s", value, strings.Join(excess, ", "))
+			}
+			config.Excess = value
+			return nil
+		},
+	},
+	dndSection: {
+		"allow-urgencies": func(config *Config, value string) error {
+			urgencies, err := urgenciesValue(value)
+			config.DNDExceptions.Urgencies = urgencies
+			return err
+		},
+		"allow-apps": func(config *Config, value string) error {
+			config.DNDExceptions.Apps = listValue(value)
+			return nil
+		},
+	},
+}
+
+func intField(min int, max int, set func(*Config, int)) field {
+	return func(config *Config, value string) error {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		if parsed < min || parsed > max {
+			return fmt.Errorf("%d is out of the range %d to %d", parsed, min, max)
+		}
+		set(config, parsed)
+		return nil
+	}
+}
+
+func floatField(min float64, max float64, set func(*Config, float64)) field {
+	return func(config *Config, value string) error {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		if parsed < min || parsed > max {
+			return fmt.Errorf("%g is out of the range %g to %g", parsed, min, max)
+		}
+		set(config, parsed)
+		return nil
+	}
+}
+
+func durationField(set func(*Config, time.Duration)) field {
+	return func(config *Config, value string) error {
+		parsed, err := durationValue(value)
+		if err != nil {
+			return err
+		}
+		set(config, parsed)
+		return nil
+	}
+}
+
+// durationValue parses Go durations, plus days as in "30d"
+func durationValue(value string) (time.Duration, error) {
+	if days := strings.TrimSuffix(value, "d"); days != value {
+		parsed, err := strconv.Atoi(days)
+		if err != nil || parsed < 0 {
+			return 0, fmt.Errorf("%q is not a number of days", value)
+		}
+		return time.Duration(parsed) * 24 * time.Hour, nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed < 0 {
+		return 0, fmt.Errorf("%q is not a duration such as 90s, 12h or 30d", value)
+	}
+	return parsed, nil
+}
+
+// urgenciesValue parses a comma separated list of urgencies, or none
+func urgenciesValue(value string) ([]schema.Urgency, error) {
+	urgencies := []schema.Urgency{}
+	if strings.TrimSpace(value) == "none" {
+		return urgencies, nil
+	}
+	for _, name := range strings.Split(value, ",") {
+		urgency, err := schema.ParseUrgency(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		urgencies = append(urgencies, urgency)
+	}
+	return urgencies, nil
+}
+
+// listValue parses a comma separated list, or none
+func listValue(value string) []string {
+	values := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" && item != "none" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+func capabilitiesValue(value string) ([]string, error) {
+	capabilities := []string{}
+	for _, capability := range strings.Split(value, ",") {
+		capability = strings.TrimSpace(capability)
+		if capability == "" {
+			continue
+		}
+		if !contains(SupportedCapabilities, capability) {
+			return nil, fmt.Errorf("unsupported capability %q, expected some of: %s", capability, strings.Join(SupportedCapabilities, ", "))
+		}
+		capabilities = append(capabilities, capability)
+	}
+	return capabilities, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+func keys(fields map[string]field) string {
+	var names []string
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ahirata/notifyme/internal/pkg/layout"
+)
+
+func TestParseSample(t *testing.T) {
+	file, err := os.Open("../../../configs/config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	config, err := Parse(file, "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config, Default()) {
+		t.Fatalf("the sample configuration differs from the defaults:\n%+v\n%+v", config, Default())
+	}
+}
+
+func TestParse(t *testing.T) {
+	config, err := Parse(strings.NewReader(`
+timeout = 5000
+[popup]
+icon-size = 32
+anchor = top-center
+stacking = newest-nearest
+[history]
+max-age = 12h
+`), "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Timeout != 5000 || config.IconSize != 32 || config.HistoryMaxAge != 12*time.Hour || config.OffsetX != 10 {
+		t.Fatalf("unexpected configuration %+v", config)
+	}
+	if config.Anchor != layout.TopCenter || config.Stacking != layout.NewestNearest {
+		t.Fatalf("unexpected configuration %+v", config)
+	}
+}
+
+func TestParseReportsEveryError(t *testing.T) {
+	_, err := Parse(strings.NewReader(`
+timeout = soon
+[popup]
+icon-size = 4096
+colour = red
+[widgets]
+broken line
+`), "config")
+
+	errs, ok := err.(Errors)
+	if !ok {
+		t.Fatalf("expected Errors, got %v", err)
+	}
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "config:7") {
+		t.Fatalf("expected the syntax error alone, got %v", errs)
+	}
+
+	_, err = Parse(strings.NewReader(`
+timeout = soon
+[popup]
+icon-size = 4096
+colour = red
+[widgets]
+`), "config")
+	expected := []string{"config:2: invalid timeout", "config:4: invalid icon-size", `config:5: unknown key "colour"`, "config:6: unknown section [widgets]"}
+	for _, message := range expected {
+		if !strings.Contains(err.Error(), message) {
+			t.Errorf("expected %q in:\n%v", message, err)
+		}
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	config, err := Load(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || !reflect.DeepEqual(config, Default()) {
+		t.Fatalf("expected the defaults, got %+v, %v", config, err)
+	}
+}
+
+func TestWatchReloadsOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte("timeout = 1000\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := make(chan *Config, 1)
+	stop := Watch(path, 10*time.Millisecond, func(config *Config) { reloaded <- config })
+	defer stop()
+
+	time.Sleep(20 * time.Millisecond)
+	if err := os.WriteFile(path, []byte("timeout = 2000\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Second)
+	os.Chtimes(path, future, future)
+
+	select {
+	case config := <-reloaded:
+		if config.Timeout != 2000 {
+			t.Fatalf("unexpected timeout %d", config.Timeout)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("configuration was not reloaded")
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	config, err := Parse(strings.NewReader(`
+[rule ci-bots]
+app-name = Jenkins
+set-urgency = low
+
+[rule "pager"]
+summary = ^PAGE: (.*)$
+rewrite-summary = $1
+`), "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Rules) != 2 || config.Rules[0].Name != "ci-bots" || config.Rules[1].Name != "pager" {
+		t.Fatalf("unexpected rules %+v", config.Rules)
+	}
+
+	_, err = Parse(strings.NewReader("[rule]\n[rule broken]\nsummary = (\n"), "config")
+	for _, message := range []string{"config:1: rules need a name", "config:3: invalid summary"} {
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("expected %q in:\n%v", message, err)
+		}
+	}
+}
+
+func TestParseDoNotDisturb(t *testing.T) {
+	config, err := Parse(strings.NewReader(`
+[do-not-disturb]
+allow-urgencies = none
+allow-apps = pager, org.example.Alarm
+
+[quiet-hours night]
+days = mon-fri
+from = 22:00
+to = 07:00
+`), "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.DNDExceptions.Urgencies) != 0 || !reflect.DeepEqual(config.DNDExceptions.Apps, []string{"pager", "org.example.Alarm"}) {
+		t.Fatalf("unexpected exceptions %+v", config.DNDExceptions)
+	}
+	if len(config.QuietHours) != 1 || config.QuietHours[0].Name != "night" || config.QuietHours[0].Start != 22*time.Hour {
+		t.Fatalf("unexpected quiet hours %+v", config.QuietHours)
+	}
+
+	_, err = Parse(strings.NewReader("[quiet-hours]\n[quiet-hours broken]\nfrom = late\n"), "config")
+	for _, message := range []string{"config:1: quiet hours need a name", "config:3: invalid from"} {
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("expected %q in:\n%v", message, err)
+		}
+	}
+}
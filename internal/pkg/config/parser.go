@@ -0,0 +1,81 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// section is a "[name]" or "[name argument]" header followed by "key = value" settings
+type section struct {
+	name     string
+	argument string
+	line     int
+	settings []setting
+}
+
+type setting struct {
+	key   string
+	value string
+	line  int
+}
+
+// Errors holds every problem found in a configuration file
+type Errors []error
+
+func (errs Errors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// parse splits the file in sections. Settings before the first header belong to the general section
+func parse(reader io.Reader, name string) ([]*section, error) {
+	current := &section{name: generalSection}
+	sections := []*section{current}
+	var errs Errors
+
+	scanner := bufio.NewScanner(reader)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		switch {
+		case text == "" || strings.HasPrefix(text, "#") || strings.HasPrefix(text, ";"):
+			continue
+		case strings.HasPrefix(text, "["):
+			if !strings.HasSuffix(text, "]") {
+				errs = append(errs, fmt.Errorf("%s:%d: section header %q is missing the closing ]", name, line, text))
+				continue
+			}
+			fields := strings.Fields(strings.TrimSuffix(strings.TrimPrefix(text, "["), "]"))
+			if len(fields) == 0 || len(fields) > 2 {
+				errs = append(errs, fmt.Errorf("%s:%d: expected [name] or [name argument], got %q", name, line, text))
+				continue
+			}
+			current = &section{name: fields[0], line: line}
+			if len(fields) == 2 {
+				current.argument = strings.Trim(fields[1], `"`)
+			}
+			sections = append(sections, current)
+		default:
+			separator := strings.Index(text, "=")
+			if separator < 0 {
+				errs = append(errs, fmt.Errorf("%s:%d: expected key = value, got %q", name, line, text))
+				continue
+			}
+			key := strings.TrimSpace(text[:separator])
+			value := strings.Trim(strings.TrimSpace(text[separator+1:]), `"`)
+			current.settings = append(current.settings, setting{key: key, value: value, line: line})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %v", name, err))
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return sections, nil
+}
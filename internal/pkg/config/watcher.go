@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Watch reloads the configuration at path on SIGHUP or when the file changes, checking it every interval.
+// Valid configurations are passed to apply, while invalid ones are reported and ignored. Calling the returned
+// function stops watching
+func Watch(path string, interval time.Duration, apply func(*Config)) func() {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		defer signal.Stop(hangup)
+
+		lastModified := modified(path)
+		for {
+			select {
+			case <-done:
+				return
+			case <-hangup:
+				fmt.Println("Received SIGHUP, reloading", path)
+			case <-ticker.C:
+				current := modified(path)
+				if current.Equal(lastModified) {
+					continue
+				}
+				lastModified = current
+				fmt.Println("Configuration changed, reloading", path)
+			}
+
+			config, err := Load(path)
+			if err != nil {
+				fmt.Println("Keeping the current configuration:", err)
+				continue
+			}
+			apply(config)
+		}
+	}()
+
+	return func() { close(done) }
+}
+
+// modified returns the modification time of the file, or the zero time if it does not exist
+func modified(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
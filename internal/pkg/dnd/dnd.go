@@ -0,0 +1,93 @@
+package dnd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ahirata/notifyme/pkg/notifyme/schema"
+)
+
+// Mode is the do not disturb setting chosen by the user
+type Mode int
+
+const (
+	// Auto holds the notifications back during the quiet hours only
+	Auto Mode = iota
+	// On holds the notifications back
+	On
+	// Off shows the notifications, even during the quiet hours
+	Off
+)
+
+var modeNames = []string{"auto", "on", "off"}
+
+func (mode Mode) String() string {
+	if mode < Auto || mode > Off {
+		return fmt.Sprintf("Mode(%d)", int(mode))
+	}
+	return modeNames[mode]
+}
+
+// ParseMode returns the mode with the given name: auto, on or off
+func ParseMode(name string) (Mode, error) {
+	for mode, modeName := range modeNames {
+		if name == modeName {
+			return Mode(mode), nil
+		}
+	}
+	return Auto, fmt.Errorf("unknown mode %q, expected one of: %s", name, strings.Join(modeNames, ", "))
+}
+
+// State is the mode set by the user, in effect until the given time, after which it is back to Auto.
+// A zero Until keeps the mode until it is changed
+type State struct {
+	Mode  Mode
+	Until time.Time
+}
+
+// Effective returns the mode in effect at now
+func (state State) Effective(now time.Time) Mode {
+	if !state.Until.IsZero() && !now.Before(state.Until) {
+		return Auto
+	}
+	return state.Mode
+}
+
+// Active tells whether the notifications are held back at now, given the quiet hours
+func (state State) Active(schedules []*Schedule, now time.Time) bool {
+	switch state.Effective(now) {
+	case On:
+		return true
+	case Off:
+		return false
+	}
+	for _, schedule := range schedules {
+		if schedule.Active(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// Exceptions are the notifications shown even while do not disturb is active
+type Exceptions struct {
+	Urgencies []schema.Urgency
+	Apps      []string
+}
+
+// Allows returns true if the notification gets through do not disturb
+func (exceptions Exceptions) Allows(notification *schema.Notification) bool {
+	for _, urgency := range exceptions.Urgencies {
+		if urgency == notification.Urgency {
+			return true
+		}
+	}
+	desktopEntry, _, _ := notification.Hints.DesktopEntry()
+	for _, app := range exceptions.Apps {
+		if app == notification.AppName || app == desktopEntry {
+			return true
+		}
+	}
+	return false
+}
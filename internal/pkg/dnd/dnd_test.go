@@ -0,0 +1,118 @@
+package dnd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ahirata/notifyme/pkg/notifyme/schema"
+	"github.com/godbus/dbus"
+)
+
+// 2021-03-01 was a Monday
+func at(day int, clock string) time.Time {
+	parsed, _ := time.Parse("15:04", clock)
+	return time.Date(2021, 3, day, parsed.Hour(), parsed.Minute(), 0, 0, time.Local)
+}
+
+func TestScheduleActive(t *testing.T) {
+	night := ScheduleNew("night")
+	for key, value := range map[string]string{"days": "mon-fri", "from": "22:00", "to": "07:00"} {
+		if err := night.Set(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		time     time.Time
+		expected bool
+	}{
+		{at(1, "21:59"), false},
+		{at(1, "22:00"), true},
+		{at(2, "06:59"), true},
+		{at(2, "07:00"), false},
+		{at(1, "03:00"), false}, // the night from sunday is not in the schedule
+		{at(6, "03:00"), true},  // the night from friday is
+		{at(6, "23:00"), false},
+	}
+	for _, c := range cases {
+		if active := night.Active(c.time); active != c.expected {
+			t.Errorf("expected %t at %s, got %t", c.expected, c.time.Format("Mon 15:04"), active)
+		}
+	}
+}
+
+func TestScheduleDays(t *testing.T) {
+	weekend := ScheduleNew("weekend")
+	if err := weekend.Set("days", "fri-mon"); err != nil {
+		t.Fatal(err)
+	}
+	expected := [7]bool{true, true, false, false, false, true, true}
+	if weekend.Days != expected {
+		t.Fatalf("expected %v, got %v", expected, weekend.Days)
+	}
+	if !weekend.Active(at(7, "12:00")) || weekend.Active(at(3, "12:00")) {
+		t.Fatal("a schedule without hours should last all day")
+	}
+
+	for key, value := range map[string]string{"days": "someday", "from": "25:00", "to": "7", "start": "10:00"} {
+		if err := weekend.Set(key, value); err == nil {
+			t.Errorf("expected an error setting %s to %q", key, value)
+		}
+	}
+}
+
+func TestStateActive(t *testing.T) {
+	night := ScheduleNew("night")
+	night.Start = 22 * time.Hour
+	night.End = 7 * time.Hour
+	schedules := []*Schedule{night}
+
+	cases := []struct {
+		name     string
+		state    State
+		time     time.Time
+		expected bool
+	}{
+		{"auto out of the quiet hours", State{Mode: Auto}, at(1, "12:00"), false},
+		{"auto in the quiet hours", State{Mode: Auto}, at(1, "23:00"), true},
+		{"on", State{Mode: On}, at(1, "12:00"), true},
+		{"off in the quiet hours", State{Mode: Off}, at(1, "23:00"), false},
+		{"on until later", State{Mode: On, Until: at(1, "13:00")}, at(1, "12:00"), true},
+		{"on until earlier", State{Mode: On, Until: at(1, "11:00")}, at(1, "12:00"), false},
+		{"off until earlier", State{Mode: Off, Until: at(1, "22:30")}, at(1, "23:00"), true},
+	}
+	for _, c := range cases {
+		if active := c.state.Active(schedules, c.time); active != c.expected {
+			t.Errorf("%s: expected %t, got %t", c.name, c.expected, active)
+		}
+	}
+}
+
+func TestExceptions(t *testing.T) {
+	exceptions := Exceptions{Urgencies: []schema.Urgency{schema.Critical}, Apps: []string{"pager"}}
+	cases := []struct {
+		notification schema.Notification
+		expected     bool
+	}{
+		{schema.Notification{AppName: "chat"}, false},
+		{schema.Notification{AppName: "chat", Urgency: schema.Critical}, true},
+		{schema.Notification{AppName: "pager"}, true},
+		{schema.Notification{AppName: "Pager", Hints: map[string]dbus.Variant{"desktop-entry": dbus.MakeVariant("pager")}}, true},
+	}
+	for _, c := range cases {
+		if allows := exceptions.Allows(&c.notification); allows != c.expected {
+			t.Errorf("expected %t for %+v, got %t", c.expected, c.notification, allows)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for _, mode := range []Mode{Auto, On, Off} {
+		if parsed, err := ParseMode(mode.String()); err != nil || parsed != mode {
+			t.Errorf("expected %s, got %s (%v)", mode, parsed, err)
+		}
+	}
+	if _, err := ParseMode("maybe"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
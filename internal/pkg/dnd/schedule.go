@@ -0,0 +1,106 @@
+package dnd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var dayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Schedule is a recurring period of quiet hours. A period that ends before it starts goes past midnight,
+// and belongs to the day it starts on
+type Schedule struct {
+	Name  string
+	Days  [7]bool
+	Start time.Duration
+	End   time.Duration
+}
+
+// ScheduleNew creates a schedule lasting all day, every day
+func ScheduleNew(name string) *Schedule {
+	return &Schedule{Name: name, Days: [7]bool{true, true, true, true, true, true, true}}
+}
+
+// Set parses a setting of the schedule: days, from or to
+func (schedule *Schedule) Set(key string, value string) error {
+	var err error
+	switch key {
+	case "days":
+		schedule.Days, err = daysValue(value)
+	case "from":
+		schedule.Start, err = clockValue(value)
+	case "to":
+		schedule.End, err = clockValue(value)
+	default:
+		return fmt.Errorf("unknown key %q in [quiet-hours %s], expected one of: %s", key, schedule.Name, strings.Join(Keys, ", "))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", key, err)
+	}
+	return nil
+}
+
+// Keys are the settings accepted by Set
+var Keys = []string{"days", "from", "to"}
+
+// Active tells whether now falls in the quiet hours
+func (schedule *Schedule) Active(now time.Time) bool {
+	// the wall clock rather than the time since midnight, which is off on the days the clocks change
+	clock := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute + time.Duration(now.Second())*time.Second
+	today := schedule.Days[now.Weekday()]
+	yesterday := schedule.Days[(now.Weekday()+6)%7]
+
+	switch {
+	case schedule.Start == schedule.End:
+		return today
+	case schedule.Start < schedule.End:
+		return today && clock >= schedule.Start && clock < schedule.End
+	default:
+		return today && clock >= schedule.Start || yesterday && clock < schedule.End
+	}
+}
+
+// daysValue parses a comma separated list of days or ranges of days, as in "mon-fri" or "sat, sun"
+func daysValue(value string) ([7]bool, error) {
+	var days [7]bool
+	for _, item := range strings.Split(value, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(item), "-", 2)
+		first, err := dayValue(bounds[0])
+		if err != nil {
+			return days, err
+		}
+		last := first
+		if len(bounds) == 2 {
+			if last, err = dayValue(bounds[1]); err != nil {
+				return days, err
+			}
+		}
+		for day := first; ; day = (day + 1) % 7 {
+			days[day] = true
+			if day == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func dayValue(name string) (int, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for day, dayName := range dayNames {
+		if name == dayName {
+			return day, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown day %q, expected one of: %s", name, strings.Join(dayNames, ", "))
+}
+
+// clockValue parses a time of the day as in "22:30"
+func clockValue(value string) (time.Duration, error) {
+	parsed, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a time of the day such as 22:30", value)
+	}
+	return time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute, nil
+}
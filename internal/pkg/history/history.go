@@ -0,0 +1,265 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ahirata/notifyme/pkg/notifyme/schema"
+)
+
+const fileName = "history.jsonl"
+
+// Entry is a notification recorded in the history
+type Entry struct {
+	ID             uint32 `json:"id"`
+	NotificationID uint32 `json:"notification_id"`
+	Timestamp      int64  `json:"timestamp"`
+	AppName        string `json:"app_name"`
+	AppIcon        string `json:"app_icon"`
+	Summary        string `json:"summary"`
+	Body           string `json:"body"`
+	Category       string `json:"category"`
+	Urgency        byte   `json:"urgency"`
+	Muted          bool   `json:"muted"`
+	Image          string `json:"image"`
+}
+
+// History keeps the notifications on disk, dropping the oldest ones past the retention limits
+type History struct {
+	lock       sync.Mutex
+	dir        string
+	maxEntries int
+	maxAge     time.Duration
+	entries    []Entry
+	counter    uint32
+	now        func() time.Time
+}
+
+// DefaultDir returns $XDG_DATA_HOME/notifyme, falling back to ~/.local/share/notifyme
+func DefaultDir() string {
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(os.Getenv("HOME"), ".local", "share")
+	}
+	return filepath.Join(dataHome, "notifyme")
+}
+
+// HistoryNew loads the history kept in dir. A maxEntries or maxAge of zero disables that limit
+func HistoryNew(dir string, maxEntries int, maxAge time.Duration) (*History, error) {
+	history := &History{dir: dir, maxEntries: maxEntries, maxAge: maxAge, now: time.Now}
+	if err := os.MkdirAll(filepath.Join(dir, thumbnailDir), 0700); err != nil {
+		return nil, err
+	}
+	if err := history.load(); err != nil {
+		return nil, err
+	}
+	history.prune()
+	if err := history.save(); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+func (history *History) load() error {
+	file, err := os.Open(filepath.Join(history.dir, fileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			fmt.Printf("Skipping history line %d: %v\n", line, err)
+			continue
+		}
+		history.entries = append(history.entries, entry)
+		if entry.ID > history.counter {
+			history.counter = entry.ID
+		}
+	}
+	return scanner.Err()
+}
+
+// save rewrites the whole history file
+func (history *History) save() error {
+	path := filepath.Join(history.dir, fileName)
+	file, err := os.OpenFile(path+".tmp", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(file)
+	for _, entry := range history.entries {
+		if err := encoder.Encode(entry); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+func (history *History) append(entry Entry) error {
+	file, err := os.OpenFile(filepath.Join(history.dir, fileName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return json.NewEncoder(file).Encode(entry)
+}
+
+// prune drops the entries past the retention limits, returning true if any was dropped.
+// Past maxEntries it drops a tenth of them at once, so that the file isn't rewritten on every record
+func (history *History) prune() bool {
+	first := 0
+	if history.maxEntries > 0 && len(history.entries) > history.maxEntries {
+		first = len(history.entries) - (history.maxEntries - history.maxEntries/10)
+	}
+	if history.maxAge > 0 {
+		oldest := history.now().Add(-history.maxAge).Unix()
+		for first < len(history.entries) && history.entries[first].Timestamp < oldest {
+			first++
+		}
+	}
+
+	for _, entry := range history.entries[:first] {
+		history.removeThumbnail(entry)
+	}
+	history.entries = history.entries[first:]
+	return first > 0
+}
+
+// SetLimits changes the retention limits, dropping the entries past them
+func (history *History) SetLimits(maxEntries int, maxAge time.Duration) error {
+	history.lock.Lock()
+	defer history.lock.Unlock()
+
+	history.maxEntries = maxEntries
+	history.maxAge = maxAge
+	if history.prune() {
+		return history.save()
+	}
+	return nil
+}
+
+// Record adds the notification to the history
+func (history *History) Record(notification *schema.Notification, muted bool) (Entry, error) {
+	history.lock.Lock()
+	defer history.lock.Unlock()
+
+	history.counter++
+	category, _, _ := notification.Hints.Category()
+	entry := Entry{
+		ID:             history.counter,
+		NotificationID: notification.ID,
+		Timestamp:      history.now().Unix(),
+		AppName:        notification.AppName,
+		AppIcon:        notification.AppIcon,
+		Summary:        notification.Summary,
+		Body:           notification.Body,
+		Category:       category,
+		Urgency:        byte(notification.Urgency),
+		Muted:          muted,
+	}
+	image, err := history.image(entry.ID, notification)
+	if err != nil {
+		fmt.Println("Unable to keep the notification image:", err)
+	}
+	entry.Image = image
+
+	history.entries = append(history.entries, entry)
+	if history.prune() {
+		return entry, history.save()
+	}
+	return entry, history.append(entry)
+}
+
+// List returns up to limit entries, newest first, skipping the first offset ones. A limit of zero means no limit
+func (history *History) List(offset int, limit int) []Entry {
+	history.lock.Lock()
+	defer history.lock.Unlock()
+
+	return page(history.newestFirst(func(Entry) bool { return true }), offset, limit)
+}
+
+// Search returns up to limit entries, newest first, whose app name, summary or body contain the query ignoring case
+func (history *History) Search(query string, limit int) []Entry {
+	history.lock.Lock()
+	defer history.lock.Unlock()
+
+	query = strings.ToLower(query)
+	matches := func(entry Entry) bool {
+		for _, field := range []string{entry.AppName, entry.Summary, entry.Body} {
+			if strings.Contains(strings.ToLower(field), query) {
+				return true
+			}
+		}
+		return false
+	}
+	return page(history.newestFirst(matches), 0, limit)
+}
+
+// Held returns up to limit entries that were muted, newest first, skipping the first offset ones.
+// A limit of zero means no limit
+func (history *History) Held(offset int, limit int) []Entry {
+	history.lock.Lock()
+	defer history.lock.Unlock()
+
+	return page(history.newestFirst(func(entry Entry) bool { return entry.Muted }), offset, limit)
+}
+
+// Get returns the entry with the given history id
+func (history *History) Get(id uint32) (Entry, bool) {
+	history.lock.Lock()
+	defer history.lock.Unlock()
+
+	for _, entry := range history.entries {
+		if entry.ID == id {
+			return entry, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Len returns the number of entries
+func (history *History) Len() int {
+	history.lock.Lock()
+	defer history.lock.Unlock()
+
+	return len(history.entries)
+}
+
+func (history *History) newestFirst(filter func(Entry) bool) []Entry {
+	var entries []Entry
+	for i := len(history.entries) - 1; i >= 0; i-- {
+		if filter(history.entries[i]) {
+			entries = append(entries, history.entries[i])
+		}
+	}
+	return entries
+}
+
+func page(entries []Entry, offset int, limit int) []Entry {
+	if offset >= len(entries) {
+		return []Entry{}
+	}
+	entries = entries[offset:]
+	if limit > 0 && limit < len(entries) {
+		entries = entries[:limit]
+	}
+	return entries
+}
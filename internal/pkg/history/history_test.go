@@ -0,0 +1,121 @@
+package history
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ahirata/notifyme/pkg/notifyme/schema"
+	"github.com/godbus/dbus"
+)
+
+func record(t *testing.T, history *History, summary string, hints schema.Hints) Entry {
+	t.Helper()
+	entry, err := history.Record(&schema.Notification{ID: 1, AppName: "test", Summary: summary, Hints: hints}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+func TestHistoryPersists(t *testing.T) {
+	dir := t.TempDir()
+	history, err := HistoryNew(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record(t, history, "first", nil)
+	record(t, history, "second", nil)
+
+	reloaded, err := HistoryNew(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := reloaded.List(0, 0)
+	if len(entries) != 2 || entries[0].Summary != "second" || entries[1].Summary != "first" {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+	if third := record(t, reloaded, "third", nil); third.ID != 3 {
+		t.Fatalf("expected ids to continue after reload, got %d", third.ID)
+	}
+}
+
+func TestHistoryRetention(t *testing.T) {
+	history, err := HistoryNew(t.TempDir(), 2, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	history.now = func() time.Time { return now }
+
+	record(t, history, "first", nil)
+	record(t, history, "second", nil)
+	record(t, history, "third", nil)
+	if entries := history.List(0, 0); len(entries) != 2 || entries[1].Summary != "second" {
+		t.Fatalf("expected the oldest entry to be dropped, got %+v", entries)
+	}
+
+	now = now.Add(2 * time.Hour)
+	record(t, history, "fourth", nil)
+	if entries := history.List(0, 0); len(entries) != 1 || entries[0].Summary != "fourth" {
+		t.Fatalf("expected the old entries to expire, got %+v", entries)
+	}
+}
+
+func TestHistoryPrunesInBatches(t *testing.T) {
+	history, err := HistoryNew(t.TempDir(), 20, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 21; i++ {
+		record(t, history, "entry", nil)
+	}
+	if entries := history.List(0, 0); len(entries) != 18 || entries[17].ID != 4 {
+		t.Fatalf("expected the oldest tenth to be dropped at once, got %d entries", len(entries))
+	}
+	record(t, history, "entry", nil)
+	if length := history.Len(); length != 19 {
+		t.Fatalf("expected the next record to be appended, got %d entries", length)
+	}
+}
+
+func TestHistorySearch(t *testing.T) {
+	history, err := HistoryNew(t.TempDir(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record(t, history, "Build failed", nil)
+	record(t, history, "New mail", nil)
+	record(t, history, "Build passed", nil)
+
+	entries := history.Search("BUILD", 1)
+	if len(entries) != 1 || entries[0].Summary != "Build passed" {
+		t.Fatalf("unexpected search result %+v", entries)
+	}
+}
+
+func TestHistoryThumbnail(t *testing.T) {
+	dir := t.TempDir()
+	history, err := HistoryNew(dir, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 200*100*4)
+	hints := schema.Hints{"image-data": dbus.MakeVariant([]interface{}{int32(200), int32(100), int32(800), true, int32(8), int32(4), data})}
+
+	entry := record(t, history, "image", hints)
+	path := strings.TrimPrefix(entry.Image, "file://")
+	if filepath.Dir(path) != filepath.Join(dir, thumbnailDir) {
+		t.Fatalf("unexpected thumbnail path %s", entry.Image)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	}
+
+	record(t, history, "next", schema.Hints{"image-path": dbus.MakeVariant("file:///tmp/image.png")})
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("thumbnail was not removed along with its entry")
+	}
+}
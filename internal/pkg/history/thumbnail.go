@@ -0,0 +1,87 @@
+package history
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ahirata/notifyme/pkg/notifyme/schema"
+)
+
+const (
+	thumbnailDir  = "thumbnails"
+	thumbnailSize = 64
+)
+
+// image returns a reference to the notification image, writing a thumbnail when it was sent as raw data
+func (history *History) image(id uint32, notification *schema.Notification) (string, error) {
+	if imageData, found := notification.ImageData(); found {
+		return history.writeThumbnail(id, &imageData)
+	}
+	if imagePath, found := notification.ImagePath(); found {
+		return imagePath, nil
+	}
+	if notification.AppIcon != "" {
+		return notification.AppIcon, nil
+	}
+	if iconData, found := notification.LegacyIconData(); found {
+		return history.writeThumbnail(id, &iconData)
+	}
+	return "", nil
+}
+
+func (history *History) writeThumbnail(id uint32, imageData *schema.ImageData) (string, error) {
+	path := filepath.Join(history.dir, thumbnailDir, fmt.Sprintf("%d.png", id))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if err := png.Encode(file, thumbnail(imageData, thumbnailSize)); err != nil {
+		return "", err
+	}
+	return "file://" + path, nil
+}
+
+func (history *History) removeThumbnail(entry Entry) {
+	prefix := "file://" + filepath.Join(history.dir, thumbnailDir) + string(filepath.Separator)
+	if strings.HasPrefix(entry.Image, prefix) {
+		os.Remove(strings.TrimPrefix(entry.Image, "file://"))
+	}
+}
+
+// thumbnail scales a validated image down to fit in size x size, keeping its aspect ratio
+func thumbnail(imageData *schema.ImageData, size int) image.Image {
+	width, height := int(imageData.Width), int(imageData.Height)
+	scaledWidth, scaledHeight := width, height
+	if width > size && width >= height {
+		scaledWidth, scaledHeight = size, height*size/width
+	} else if height > size {
+		scaledWidth, scaledHeight = width*size/height, size
+	}
+	if scaledWidth == 0 {
+		scaledWidth = 1
+	}
+	if scaledHeight == 0 {
+		scaledHeight = 1
+	}
+
+	channels := int(imageData.Channels)
+	scaled := image.NewNRGBA(image.Rect(0, 0, scaledWidth, scaledHeight))
+	for y := 0; y < scaledHeight; y++ {
+		for x := 0; x < scaledWidth; x++ {
+			offset := (y*height/scaledHeight)*int(imageData.RowStride) + (x*width/scaledWidth)*channels
+			pixel := color.NRGBA{imageData.Data[offset], imageData.Data[offset+1], imageData.Data[offset+2], 0xFF}
+			if imageData.HasAlpha {
+				pixel.A = imageData.Data[offset+3]
+			}
+			scaled.SetNRGBA(x, y, pixel)
+		}
+	}
+	return scaled
+}
@@ -0,0 +1,157 @@
+package idle
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus"
+)
+
+// Names of the idle sources accepted by Detect
+const (
+	Auto        = "auto"
+	ScreenSaver = "screensaver"
+	Logind      = "logind"
+	None        = "none"
+)
+
+// Sources lists the names accepted by Detect
+var Sources = []string{Auto, ScreenSaver, Logind, None}
+
+const (
+	screenSaverName      = "org.freedesktop.ScreenSaver"
+	screenSaverPath      = "/org/freedesktop/ScreenSaver"
+	screenSaverIdleTime  = screenSaverName + ".GetSessionIdleTime"
+	logindName           = "org.freedesktop.login1"
+	logindSessionPath    = "/org/freedesktop/login1/session/auto"
+	logindIdleHint       = logindName + ".Session.IdleHint"
+	detectionDescription = "screensaver (org.freedesktop.ScreenSaver on the session bus) or logind (IdleHint on the system bus)"
+)
+
+// Source tells whether the user is away
+type Source interface {
+	Idle() (bool, error)
+}
+
+// ScreenSaverSource asks org.freedesktop.ScreenSaver for the time since the last user input,
+// and considers the user idle past the threshold
+type ScreenSaverSource struct {
+	object    dbus.BusObject
+	threshold time.Duration
+}
+
+// ScreenSaverSourceNew creates a source querying the screensaver service on conn, usually the session bus
+func ScreenSaverSourceNew(conn *dbus.Conn, threshold time.Duration) *ScreenSaverSource {
+	return &ScreenSaverSource{object: conn.Object(screenSaverName, screenSaverPath), threshold: threshold}
+}
+
+// Idle returns true if there was no user input for longer than the threshold
+func (source *ScreenSaverSource) Idle() (bool, error) {
+	var seconds uint32
+	if err := source.object.Call(screenSaverIdleTime, 0).Store(&seconds); err != nil {
+		return false, err
+	}
+	return time.Duration(seconds)*time.Second >= source.threshold, nil
+}
+
+// LogindSource reads the IdleHint of the current session from systemd-logind, which applies its own threshold
+type LogindSource struct {
+	object dbus.BusObject
+}
+
+// LogindSourceNew creates a source reading the session of logind on conn, usually the system bus
+func LogindSourceNew(conn *dbus.Conn) *LogindSource {
+	return &LogindSource{object: conn.Object(logindName, logindSessionPath)}
+}
+
+// Idle returns the IdleHint of the session
+func (source *LogindSource) Idle() (bool, error) {
+	variant, err := source.object.GetProperty(logindIdleHint)
+	if err != nil {
+		return false, err
+	}
+	idle, ok := variant.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("unexpected %s of type %s", logindIdleHint, variant.Signature())
+	}
+	return idle, nil
+}
+
+// Detect returns the named source, or the first one that answers for auto. It returns nil for none
+func Detect(name string, threshold time.Duration) (Source, error) {
+	switch name {
+	case None:
+		return nil, nil
+	case ScreenSaver:
+		return probe(func() (Source, error) {
+			conn, err := dbus.SessionBus()
+			if err != nil {
+				return nil, err
+			}
+			return ScreenSaverSourceNew(conn, threshold), nil
+		})
+	case Logind:
+		return probe(func() (Source, error) {
+			conn, err := dbus.SystemBus()
+			if err != nil {
+				return nil, err
+			}
+			return LogindSourceNew(conn), nil
+		})
+	case Auto:
+		for _, candidate := range []string{ScreenSaver, Logind} {
+			if source, err := Detect(candidate, threshold); err == nil {
+				return source, nil
+			}
+		}
+		return nil, fmt.Errorf("no idle source answered, tried %s", detectionDescription)
+	}
+	return nil, fmt.Errorf("unknown idle source %q, expected one of: %s", name, strings.Join(Sources, ", "))
+}
+
+// probe creates a source and checks that it answers
+func probe(create func() (Source, error)) (Source, error) {
+	source, err := create()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := source.Idle(); err != nil {
+		return nil, err
+	}
+	return source, nil
+}
+
+// Watch polls the source every interval and calls apply whenever the user becomes idle or active.
+// Errors are reported and count as activity, so that a broken source never freezes the timers.
+// Calling the returned function stops watching
+func Watch(source Source, interval time.Duration, apply func(idle bool)) func() {
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		idle := false
+		var lastErr error
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			current, err := source.Idle()
+			if err != nil && (lastErr == nil || err.Error() != lastErr.Error()) {
+				fmt.Println("Error reading the idle state:", err)
+			}
+			lastErr = err
+			if current != idle {
+				idle = current
+				apply(idle)
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
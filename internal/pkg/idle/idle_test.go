@@ -0,0 +1,156 @@
+package idle
+
+import (
+	"bufio"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus"
+	"github.com/godbus/dbus/prop"
+)
+
+// startBus launches a private dbus-daemon standing in for the session and system buses
+func startBus(t *testing.T) *dbus.Conn {
+	t.Helper()
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+
+	socket := filepath.Join(t.TempDir(), "bus")
+	daemon := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address=1", "--address=unix:path="+socket)
+	stdout, err := daemon.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := daemon.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		daemon.Process.Kill()
+		daemon.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("reading bus address: %v", err)
+	}
+	conn, err := dbus.Dial(strings.TrimSpace(address))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Auth(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Hello(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func requestName(t *testing.T, conn *dbus.Conn, name string) {
+	t.Helper()
+	if reply, err := conn.RequestName(name, dbus.NameFlagDoNotQueue); err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("could not own %s: %v", name, err)
+	}
+}
+
+// fakeScreenSaver reports the idle time it was given
+type fakeScreenSaver struct {
+	seconds uint32
+}
+
+func (screenSaver *fakeScreenSaver) GetSessionIdleTime() (uint32, *dbus.Error) {
+	return atomic.LoadUint32(&screenSaver.seconds), nil
+}
+
+func TestScreenSaverSource(t *testing.T) {
+	conn := startBus(t)
+	screenSaver := &fakeScreenSaver{seconds: 10}
+	conn.Export(screenSaver, screenSaverPath, screenSaverName)
+	requestName(t, conn, screenSaverName)
+
+	source := ScreenSaverSourceNew(conn, time.Minute)
+	if idle, err := source.Idle(); err != nil || idle {
+		t.Fatalf("expected active, got %t (%v)", idle, err)
+	}
+	atomic.StoreUint32(&screenSaver.seconds, 60)
+	if idle, err := source.Idle(); err != nil || !idle {
+		t.Fatalf("expected idle, got %t (%v)", idle, err)
+	}
+}
+
+func TestLogindSource(t *testing.T) {
+	conn := startBus(t)
+	properties := prop.New(conn, logindSessionPath, map[string]map[string]*prop.Prop{
+		logindName + ".Session": {"IdleHint": {Value: false}},
+	})
+	requestName(t, conn, logindName)
+
+	source := LogindSourceNew(conn)
+	if idle, err := source.Idle(); err != nil || idle {
+		t.Fatalf("expected active, got %t (%v)", idle, err)
+	}
+	properties.SetMust(logindName+".Session", "IdleHint", true)
+	if idle, err := source.Idle(); err != nil || !idle {
+		t.Fatalf("expected idle, got %t (%v)", idle, err)
+	}
+}
+
+func TestSourceWithoutService(t *testing.T) {
+	conn := startBus(t)
+	if _, err := ScreenSaverSourceNew(conn, time.Minute).Idle(); err == nil {
+		t.Fatal("expected an error without a screensaver service")
+	}
+}
+
+// fakeSource returns the idle state it was given
+type fakeSource struct {
+	idle int32
+}
+
+func (source *fakeSource) Idle() (bool, error) {
+	return atomic.LoadInt32(&source.idle) == 1, nil
+}
+
+func TestWatchReportsChanges(t *testing.T) {
+	source := &fakeSource{}
+	changes := make(chan bool, 10)
+	stop := Watch(source, 5*time.Millisecond, func(idle bool) { changes <- idle })
+	defer stop()
+
+	expect := func(expected bool) {
+		t.Helper()
+		select {
+		case idle := <-changes:
+			if idle != expected {
+				t.Fatalf("expected idle %t, got %t", expected, idle)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for idle %t", expected)
+		}
+	}
+
+	atomic.StoreInt32(&source.idle, 1)
+	expect(true)
+	atomic.StoreInt32(&source.idle, 0)
+	expect(false)
+	select {
+	case idle := <-changes:
+		t.Fatalf("unexpected change to %t", idle)
+	case <-time.After(30 * time.Millisecond):
+	}
+}
+
+func TestDetectUnknownSource(t *testing.T) {
+	if _, err := Detect("xscreensaver", time.Minute); err == nil {
+		t.Fatal("expected an error for an unknown source")
+	}
+	if source, err := Detect(None, time.Minute); source != nil || err != nil {
+		t.Fatalf("expected no source, got %v (%v)", source, err)
+	}
+}
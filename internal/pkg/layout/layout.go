@@ -0,0 +1,155 @@
+package layout
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Rectangle is an area of the screen, from its top left corner
+type Rectangle struct {
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+// Size is the allocated size of a popup
+type Size struct {
+	Width  int
+	Height int
+}
+
+// Anchor is the point of the workarea the popups are stacked from
+type Anchor int
+
+// Anchors where the stack can start
+const (
+	TopLeft Anchor = iota
+	TopCenter
+	TopRight
+	BottomLeft
+	BottomCenter
+	BottomRight
+	Center
+)
+
+var anchorNames = map[Anchor]string{
+	TopLeft:      "top-left",
+	TopCenter:    "top-center",
+	TopRight:     "top-right",
+	BottomLeft:   "bottom-left",
+	BottomCenter: "bottom-center",
+	BottomRight:  "bottom-right",
+	Center:       "center",
+}
+
+func (anchor Anchor) String() string {
+	return anchorNames[anchor]
+}
+
+// ParseAnchor reads an anchor name such as "bottom-right"
+func ParseAnchor(name string) (Anchor, error) {
+	var names []string
+	for anchor, anchorName := range anchorNames {
+		if anchorName == name {
+			return anchor, nil
+		}
+		names = append(names, anchorName)
+	}
+	sort.Strings(names)
+	return 0, fmt.Errorf("unknown anchor %q, expected one of: %s", name, strings.Join(names, ", "))
+}
+
+// Order tells where new popups go in the stack
+type Order int
+
+// Orders of the stack
+const (
+	// NewestFarthest keeps the oldest popup at the anchor and stacks the new ones away from it
+	NewestFarthest Order = iota
+	// NewestNearest puts new popups at the anchor and pushes the older ones away from it
+	NewestNearest
+)
+
+var orderNames = map[Order]string{
+	NewestFarthest: "newest-farthest",
+	NewestNearest:  "newest-nearest",
+}
+
+func (order Order) String() string {
+	return orderNames[order]
+}
+
+// ParseOrder reads an order name such as "newest-nearest"
+func ParseOrder(name string) (Order, error) {
+	for order, orderName := range orderNames {
+		if orderName == name {
+			return order, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown order %q, expected newest-farthest or newest-nearest", name)
+}
+
+// Settings tells where the stack starts and how far the popups are kept from the edges of the workarea and from each other
+type Settings struct {
+	Anchor  Anchor
+	MarginX int
+	MarginY int
+	Spacing int
+}
+
+// Stack places the popups, given from the nearest to the anchor to the farthest.
+// The stack grows downwards from the top anchors and the center, and upwards from the bottom ones.
+// MarginX and MarginY keep the stack away from the edges it is anchored to, and Spacing separates the popups.
+// A centered stack is centered as a whole, ignoring the margins along the centered axis
+func Stack(workarea Rectangle, sizes []Size, settings Settings) []Rectangle {
+	positions := make([]Rectangle, len(sizes))
+	if len(sizes) == 0 {
+		return positions
+	}
+
+	var y int
+	upwards := false
+	switch settings.Anchor {
+	case TopLeft, TopCenter, TopRight:
+		y = workarea.Y + settings.MarginY
+	case BottomLeft, BottomCenter, BottomRight:
+		y = workarea.Y + workarea.Height - settings.MarginY
+		upwards = true
+	case Center:
+		y = workarea.Y + (workarea.Height-height(sizes, settings.Spacing))/2
+	}
+
+	for i, size := range sizes {
+		if upwards {
+			y -= size.Height
+		}
+		positions[i] = Rectangle{X: x(workarea, size, settings), Y: y, Width: size.Width, Height: size.Height}
+		if upwards {
+			y -= settings.Spacing
+		} else {
+			y += size.Height + settings.Spacing
+		}
+	}
+	return positions
+}
+
+func x(workarea Rectangle, size Size, settings Settings) int {
+	switch settings.Anchor {
+	case TopLeft, BottomLeft:
+		return workarea.X + settings.MarginX
+	case TopRight, BottomRight:
+		return workarea.X + workarea.Width - settings.MarginX - size.Width
+	}
+	return workarea.X + (workarea.Width-size.Width)/2
+}
+
+// height returns the height of the whole stack
+func height(sizes []Size, spacing int) int {
+	total := spacing * (len(sizes) - 1)
+	for _, size := range sizes {
+		total += size.Height
+	}
+	return total
+}
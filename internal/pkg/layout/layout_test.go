@@ -0,0 +1,67 @@
+package layout
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStack(t *testing.T) {
+	workarea := Rectangle{X: 100, Y: 20, Width: 1000, Height: 700}
+	sizes := []Size{{300, 80}, {200, 50}}
+
+	cases := []struct {
+		anchor   Anchor
+		expected []Rectangle
+	}{
+		{TopLeft, []Rectangle{{110, 25, 300, 80}, {110, 110, 200, 50}}},
+		{TopCenter, []Rectangle{{450, 25, 300, 80}, {500, 110, 200, 50}}},
+		{TopRight, []Rectangle{{790, 25, 300, 80}, {890, 110, 200, 50}}},
+		{BottomLeft, []Rectangle{{110, 635, 300, 80}, {110, 580, 200, 50}}},
+		{BottomCenter, []Rectangle{{450, 635, 300, 80}, {500, 580, 200, 50}}},
+		{BottomRight, []Rectangle{{790, 635, 300, 80}, {890, 580, 200, 50}}},
+		{Center, []Rectangle{{450, 302, 300, 80}, {500, 387, 200, 50}}},
+	}
+	for _, c := range cases {
+		t.Run(c.anchor.String(), func(t *testing.T) {
+			settings := Settings{Anchor: c.anchor, MarginX: 10, MarginY: 5, Spacing: 5}
+			if positions := Stack(workarea, sizes, settings); !reflect.DeepEqual(positions, c.expected) {
+				t.Fatalf("expected %v, got %v", c.expected, positions)
+			}
+		})
+	}
+}
+
+func TestStackEmpty(t *testing.T) {
+	if positions := Stack(Rectangle{Width: 1000, Height: 700}, nil, Settings{Anchor: Center}); len(positions) != 0 {
+		t.Fatalf("expected no positions, got %v", positions)
+	}
+}
+
+func TestStackReflowsAfterRemoval(t *testing.T) {
+	workarea := Rectangle{Width: 1000, Height: 700}
+	sizes := []Size{{300, 80}, {300, 50}, {300, 100}}
+
+	positions := Stack(workarea, append(sizes[:1:1], sizes[2:]...), Settings{Anchor: BottomRight})
+	if positions[1].Y != 700-80-100 {
+		t.Fatalf("expected the popup to take the freed space, got %v", positions[1])
+	}
+}
+
+func TestParse(t *testing.T) {
+	for anchor, name := range anchorNames {
+		if parsed, err := ParseAnchor(name); err != nil || parsed != anchor {
+			t.Fatalf("expected %v, got %v (%v)", anchor, parsed, err)
+		}
+	}
+	for order, name := range orderNames {
+		if parsed, err := ParseOrder(name); err != nil || parsed != order {
+			t.Fatalf("expected %v, got %v (%v)", order, parsed, err)
+		}
+	}
+	if _, err := ParseAnchor("middle"); err == nil {
+		t.Fatal("expected an error for an unknown anchor")
+	}
+	if _, err := ParseOrder("random"); err == nil {
+		t.Fatal("expected an error for an unknown order")
+	}
+}
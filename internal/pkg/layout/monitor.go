@@ -0,0 +1,74 @@
+package layout
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Output describes a monitor and its workarea
+type Output struct {
+	Manufacturer string
+	Model        string
+	Workarea     Rectangle
+	Primary      bool
+	// Pointer tells whether the mouse pointer is on this monitor
+	Pointer bool
+	// Focused tells whether the focused window is on this monitor
+	Focused bool
+}
+
+// Monitor choices besides output names
+const (
+	PrimaryMonitor = "primary"
+	MouseMonitor   = "follow-mouse"
+	FocusedMonitor = "focused"
+)
+
+// MonitorPolicy lists the monitors to show the popups on, in order of preference.
+// Each choice is primary, follow-mouse, focused or the name of an output
+type MonitorPolicy []string
+
+// ParseMonitorPolicy reads a comma separated list of choices, such as "DP-1, follow-mouse"
+func ParseMonitorPolicy(value string) (MonitorPolicy, error) {
+	var policy MonitorPolicy
+	for _, choice := range strings.Split(value, ",") {
+		if choice = strings.TrimSpace(choice); choice != "" {
+			policy = append(policy, choice)
+		}
+	}
+	if len(policy) == 0 {
+		return nil, fmt.Errorf("expected %s, %s, %s or output names", PrimaryMonitor, MouseMonitor, FocusedMonitor)
+	}
+	return policy, nil
+}
+
+func (policy MonitorPolicy) String() string {
+	return strings.Join(policy, ", ")
+}
+
+// Select returns the index of the output of the first choice that is available, falling back to the primary output
+// and then to the first one. It returns false if there are no outputs at all
+func (policy MonitorPolicy) Select(outputs []Output) (int, bool) {
+	for _, choice := range append(policy[:len(policy):len(policy)], PrimaryMonitor) {
+		for i, output := range outputs {
+			if output.is(choice) {
+				return i, true
+			}
+		}
+	}
+	return 0, len(outputs) > 0
+}
+
+// is tells whether the output is the one described by the choice.
+// Names match the model, which is the connector on X11, or the manufacturer followed by the model
+func (output Output) is(choice string) bool {
+	switch choice {
+	case PrimaryMonitor:
+		return output.Primary
+	case MouseMonitor:
+		return output.Pointer
+	case FocusedMonitor:
+		return output.Focused
+	}
+	return output.Model != "" && (choice == output.Model || choice == output.Manufacturer+" "+output.Model)
+}
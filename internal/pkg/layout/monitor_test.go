@@ -0,0 +1,52 @@
+package layout
+
+import (
+	"testing"
+)
+
+func TestMonitorPolicySelect(t *testing.T) {
+	outputs := []Output{
+		{Model: "eDP-1", Workarea: Rectangle{Width: 1}},
+		{Manufacturer: "Dell", Model: "DP-1", Primary: true, Workarea: Rectangle{Width: 2}},
+		{Model: "HDMI-1", Pointer: true, Workarea: Rectangle{Width: 3}},
+		{Model: "DP-2", Focused: true, Workarea: Rectangle{Width: 4}},
+	}
+
+	cases := []struct {
+		policy   string
+		expected int
+	}{
+		{"primary", 2},
+		{"follow-mouse", 3},
+		{"focused", 4},
+		{"DP-2", 4},
+		{"Dell DP-1", 2},
+		{"VGA-1, follow-mouse", 3},
+		{"VGA-1", 2},
+	}
+	for _, c := range cases {
+		policy, err := ParseMonitorPolicy(c.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if selected, found := policy.Select(outputs); !found || outputs[selected].Workarea.Width != c.expected {
+			t.Fatalf("%q: expected output %d, got %d", c.policy, c.expected, selected)
+		}
+	}
+}
+
+func TestMonitorPolicyFallsBackToFirstOutput(t *testing.T) {
+	policy := MonitorPolicy{MouseMonitor}
+	if selected, found := policy.Select([]Output{{Model: "eDP-1"}, {Model: "DP-1"}}); !found || selected != 0 {
+		t.Fatalf("expected the first output, got %d", selected)
+	}
+	if _, found := policy.Select(nil); found {
+		t.Fatal("expected no output without monitors")
+	}
+}
+
+func TestParseMonitorPolicy(t *testing.T) {
+	if _, err := ParseMonitorPolicy(" , "); err == nil {
+		t.Fatal("expected an error for an empty policy")
+	}
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"time"
+)
+
+// maxBuckets is the number of keys above which the full buckets are forgotten
+const maxBuckets = 1024
+
+// Limiter holds a token bucket per key. Each key may send up to burst notifications at once,
+// and earns rate tokens back per second
+type Limiter struct {
+	rate    float64
+	burst   float64
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// LimiterNew creates a limiter. A rate of zero disables it
+func LimiterNew(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{rate: rate, burst: float64(burst), buckets: map[string]*bucket{}, now: time.Now}
+}
+
+// Sized tells whether the limiter has the given rate and burst
+func (limiter *Limiter) Sized(rate float64, burst int) bool {
+	if burst < 1 {
+		burst = 1
+	}
+	return limiter.rate == rate && limiter.burst == float64(burst)
+}
+
+// Allow takes a token from the bucket of the key, returning false if it is empty
+func (limiter *Limiter) Allow(key string) bool {
+	if limiter.rate <= 0 {
+		return true
+	}
+
+	now := limiter.now()
+	current, found := limiter.buckets[key]
+	if !found {
+		limiter.prune(now)
+		current = &bucket{tokens: limiter.burst, last: now}
+		limiter.buckets[key] = current
+	}
+
+	current.refill(now, limiter.rate, limiter.burst)
+	if current.tokens < 1 {
+		return false
+	}
+	current.tokens--
+	return true
+}
+
+func (bucket *bucket) refill(now time.Time, rate float64, burst float64) {
+	if elapsed := now.Sub(bucket.last).Seconds(); elapsed > 0 {
+		bucket.tokens += elapsed * rate
+		if bucket.tokens > burst {
+			bucket.tokens = burst
+		}
+	}
+	bucket.last = now
+}
+
+// prune forgets the buckets that are full again, which behave as new ones, once there are too many keys
+func (limiter *Limiter) prune(now time.Time) {
+	if len(limiter.buckets) < maxBuckets {
+		return
+	}
+	for key, bucket := range limiter.buckets {
+		if bucket.refill(now, limiter.rate, limiter.burst); bucket.tokens >= limiter.burst {
+			delete(limiter.buckets, key)
+		}
+	}
+}
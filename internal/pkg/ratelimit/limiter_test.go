@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(rate float64, burst int) (*Limiter, *time.Time) {
+	now := time.Unix(1000, 0)
+	limiter := LimiterNew(rate, burst)
+	limiter.now = func() time.Time { return now }
+	return limiter, &now
+}
+
+func TestBurstThenRate(t *testing.T) {
+	limiter, now := newTestLimiter(2, 3)
+
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow("app") {
+			t.Fatalf("notification %d of the burst was refused", i)
+		}
+	}
+	if limiter.Allow("app") {
+		t.Fatal("expected the bucket to be empty")
+	}
+	if !limiter.Allow("other") {
+		t.Fatal("keys must have their own buckets")
+	}
+
+	*now = now.Add(500 * time.Millisecond)
+	if !limiter.Allow("app") || limiter.Allow("app") {
+		t.Fatal("expected a single token after half a second at 2 per second")
+	}
+
+	*now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		limiter.Allow("app")
+	}
+	if limiter.Allow("app") {
+		t.Fatal("the bucket must not grow past the burst")
+	}
+}
+
+func TestZeroRateDisablesTheLimit(t *testing.T) {
+	limiter, _ := newTestLimiter(0, 1)
+	for i := 0; i < 100; i++ {
+		if !limiter.Allow("app") {
+			t.Fatal("expected no limit")
+		}
+	}
+}
+
+func TestPruneForgetsFullBuckets(t *testing.T) {
+	limiter, now := newTestLimiter(1, 1)
+	for i := 0; i < maxBuckets; i++ {
+		limiter.Allow(strconv.Itoa(i))
+	}
+	*now = now.Add(time.Minute)
+	limiter.Allow("new")
+	if len(limiter.buckets) != 1 {
+		t.Fatalf("expected the full buckets to be forgotten, got %d", len(limiter.buckets))
+	}
+}
@@ -0,0 +1,153 @@
+package render
+
+import (
+	"github.com/ahirata/notifyme/internal/pkg/config"
+	"sync"
+)
+
+// Headless is a Renderer that keeps the popups in memory instead of displaying them
+type Headless struct {
+	lock   sync.Mutex
+	popups []Popup
+	queued int
+	config *config.Config
+	events chan Event
+	quit   chan struct{}
+}
+
+// HeadlessNew creates a Headless renderer
+func HeadlessNew() *Headless {
+	return &Headless{
+		config: config.Default(),
+		events: make(chan Event, 100),
+		quit:   make(chan struct{}),
+	}
+}
+
+// Show appends the popup to the visible ones
+func (headless *Headless) Show(popup Popup) {
+	headless.lock.Lock()
+	defer headless.lock.Unlock()
+	headless.popups = append(headless.popups, popup)
+}
+
+// Update replaces the visible popup with the same notification ID
+func (headless *Headless) Update(popup Popup) {
+	headless.lock.Lock()
+	defer headless.lock.Unlock()
+	for i := range headless.popups {
+		if headless.popups[i].Notification.ID == popup.Notification.ID {
+			headless.popups[i] = popup
+		}
+	}
+}
+
+// Close removes the visible popup with the given notification ID
+func (headless *Headless) Close(id uint32) {
+	headless.lock.Lock()
+	defer headless.lock.Unlock()
+	filtered := headless.popups[:0]
+	for _, popup := range headless.popups {
+		if popup.Notification.ID != id {
+			filtered = append(filtered, popup)
+		}
+	}
+	headless.popups = filtered
+}
+
+// SetQueued keeps the number of notifications waiting to be shown
+func (headless *Headless) SetQueued(count int) {
+	headless.lock.Lock()
+	defer headless.lock.Unlock()
+	headless.queued = count
+}
+
+// Queued returns the number of notifications waiting to be shown
+func (headless *Headless) Queued() int {
+	headless.lock.Lock()
+	defer headless.lock.Unlock()
+	return headless.queued
+}
+
+// Events returns the channel on which simulated interactions are delivered
+func (headless *Headless) Events() <-chan Event {
+	return headless.events
+}
+
+// Configure keeps the configuration
+func (headless *Headless) Configure(configuration *config.Config) {
+	headless.lock.Lock()
+	defer headless.lock.Unlock()
+	headless.config = configuration
+}
+
+// Config returns the last configuration applied
+func (headless *Headless) Config() *config.Config {
+	headless.lock.Lock()
+	defer headless.lock.Unlock()
+	return headless.config
+}
+
+// Quit closes the channel returned by Done
+func (headless *Headless) Quit() {
+	headless.lock.Lock()
+	defer headless.lock.Unlock()
+	select {
+	case <-headless.quit:
+	default:
+		close(headless.quit)
+	}
+}
+
+// Done returns a channel that is closed once Quit is called
+func (headless *Headless) Done() <-chan struct{} {
+	return headless.quit
+}
+
+// Visible returns a copy of the popups currently displayed, oldest first
+func (headless *Headless) Visible() []Popup {
+	headless.lock.Lock()
+	defer headless.lock.Unlock()
+	return append([]Popup(nil), headless.popups...)
+}
+
+// Get returns the visible popup with the given notification ID
+func (headless *Headless) Get(id uint32) (Popup, bool) {
+	headless.lock.Lock()
+	defer headless.lock.Unlock()
+	for _, popup := range headless.popups {
+		if popup.Notification.ID == id {
+			return popup, true
+		}
+	}
+	return Popup{}, false
+}
+
+// InvokeAction simulates the user clicking on an action of a popup
+func (headless *Headless) InvokeAction(id uint32, actionKey string) {
+	headless.events <- Event{Type: ActionInvoked, ID: id, ActionKey: actionKey}
+}
+
+// Hover simulates the pointer moving over a popup
+func (headless *Headless) Hover(id uint32) {
+	headless.events <- Event{Type: PointerEntered, ID: id}
+}
+
+// Leave simulates the pointer moving out of a popup
+func (headless *Headless) Leave(id uint32) {
+	headless.events <- Event{Type: PointerLeft, ID: id}
+}
+
+// Dismiss simulates the user dismissing a popup
+func (headless *Headless) Dismiss(id uint32) {
+	headless.events <- Event{Type: Dismissed, ID: id}
+}
+
+// DismissGroup simulates the user dismissing a whole stack, which dismisses each of its popups
+func (headless *Headless) DismissGroup(group string) {
+	for _, popup := range headless.Visible() {
+		if popup.Group == group {
+			headless.Dismiss(popup.Notification.ID)
+		}
+	}
+}
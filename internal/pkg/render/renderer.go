@@ -0,0 +1,58 @@
+package render
+
+import (
+	"github.com/ahirata/notifyme/internal/pkg/config"
+	"github.com/ahirata/notifyme/pkg/notifyme/schema"
+)
+
+// Event types
+const (
+	ActionInvoked EventType = iota
+	Dismissed
+	Failed
+	// PointerEntered and PointerLeft are sent when the pointer moves over and out of a popup
+	PointerEntered
+	PointerLeft
+)
+
+// EventType identifies what the user did to a popup
+type EventType int
+
+// Event is sent by a Renderer when the user interacts with a popup or when it fails to display one
+type Event struct {
+	Type      EventType
+	ID        uint32
+	ActionKey string
+}
+
+// Popup is a notification as it should be displayed to the user
+type Popup struct {
+	Notification *schema.Notification
+	// Classes are extra style classes for the popup
+	Classes []string
+	// Mirror shows the popup on every monitor instead of the chosen one
+	Mirror bool
+	// Group is the stack of popups this one belongs to, empty if none. Stacked popups are shown collapsed
+	// into the newest one
+	Group string
+	// Repeats is the number of identical notifications the popup stands for
+	Repeats int
+}
+
+// Renderer displays notifications to the user. Implementations must be safe to use from any goroutine
+type Renderer interface {
+	// Show displays a new popup
+	Show(popup Popup)
+	// Update replaces the contents of the popup with the same notification ID
+	Update(popup Popup)
+	// Close removes the popup with the given notification ID, if any
+	Close(id uint32)
+	// Events returns the channel on which user interactions are delivered
+	Events() <-chan Event
+	// SetQueued tells how many notifications are waiting to be shown
+	SetQueued(count int)
+	// Configure applies the configuration to the popups shown from now on
+	Configure(configuration *config.Config)
+	// Quit stops the renderer
+	Quit()
+}
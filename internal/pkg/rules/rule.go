@@ -0,0 +1,209 @@
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/ahirata/notifyme/pkg/notifyme/schema"
+)
+
+// Rule changes the notifications that match all of its criteria. A rule without criteria matches everything
+type Rule struct {
+	Name string
+
+	// criteria
+	AppName  string
+	Summary  *regexp.Regexp
+	Body     *regexp.Regexp
+	Category string
+	Urgency  *schema.Urgency
+	Sender   string
+
+	// actions
+	Timeout        *int32
+	SetUrgency     *schema.Urgency
+	Suppress       bool
+	SkipHistory    bool
+	Icon           string
+	Classes        []string
+	RewriteSummary *string
+	RewriteBody    *string
+	Mirror         bool
+}
+
+// Result holds the decisions of the matching rules that are not applied to the notification itself
+type Result struct {
+	Matched     []string
+	Suppress    bool
+	SkipHistory bool
+	Classes     []string
+	Mirror      bool
+}
+
+// Sender identifies the client that sent a notification
+type Sender interface {
+	// BusName returns the unique name of the client on the bus
+	BusName() string
+	// ProcessName returns the name of the client executable, or an empty string if unknown
+	ProcessName() string
+}
+
+// RuleNew creates a rule without criteria nor actions
+func RuleNew(name string) *Rule {
+	return &Rule{Name: name}
+}
+
+// Set parses the value of a criterion or an action of the rule
+func (rule *Rule) Set(key string, value string) error {
+	var err error
+	switch key {
+	case "app-name":
+		rule.AppName = value
+	case "summary":
+		rule.Summary, err = regexp.Compile(value)
+	case "body":
+		rule.Body, err = regexp.Compile(value)
+	case "category":
+		rule.Category = value
+	case "urgency":
+		rule.Urgency, err = urgencyValue(value)
+	case "sender":
+		rule.Sender = value
+	case "set-timeout":
+		var timeout int64
+		timeout, err = strconv.ParseInt(value, 10, 32)
+		if err != nil || timeout < 0 {
+			return fmt.Errorf("%q is not a timeout in milliseconds", value)
+		}
+		rule.Timeout = new(int32)
+		*rule.Timeout = int32(timeout)
+	case "set-urgency":
+		rule.SetUrgency, err = urgencyValue(value)
+	case "suppress":
+		rule.Suppress, err = strconv.ParseBool(value)
+	case "skip-history":
+		rule.SkipHistory, err = strconv.ParseBool(value)
+	case "set-icon":
+		rule.Icon = value
+	case "add-class":
+		rule.Classes = strings.Fields(value)
+	case "rewrite-summary":
+		rule.RewriteSummary = &value
+	case "rewrite-body":
+		rule.RewriteBody = &value
+	case "mirror":
+		rule.Mirror, err = strconv.ParseBool(value)
+	default:
+		return fmt.Errorf("unknown key %q in [rule %s], expected one of: %s", key, rule.Name, strings.Join(Keys, ", "))
+	}
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", key, err)
+	}
+	return nil
+}
+
+// Keys are the settings accepted by Set
+var Keys = []string{
+	"app-name", "summary", "body", "category", "urgency", "sender",
+	"set-timeout", "set-urgency", "suppress", "skip-history", "set-icon", "add-class", "rewrite-summary", "rewrite-body",
+	"mirror",
+}
+
+func urgencyValue(value string) (*schema.Urgency, error) {
+	urgency, err := schema.ParseUrgency(value)
+	if err != nil {
+		return nil, err
+	}
+	return &urgency, nil
+}
+
+// Matches returns true if the notification sent by sender meets every criterion of the rule
+func (rule *Rule) Matches(notification *schema.Notification, sender Sender) bool {
+	if rule.AppName != "" && rule.AppName != notification.AppName {
+		return false
+	}
+	if rule.Summary != nil && !rule.Summary.MatchString(notification.Summary) {
+		return false
+	}
+	if rule.Body != nil && !rule.Body.MatchString(notification.Body) {
+		return false
+	}
+	if rule.Category != "" && !matchesCategory(rule.Category, notification) {
+		return false
+	}
+	if rule.Urgency != nil && *rule.Urgency != notification.Urgency {
+		return false
+	}
+	if rule.Sender != "" && (sender == nil || rule.Sender != sender.BusName() && rule.Sender != sender.ProcessName()) {
+		return false
+	}
+	return true
+}
+
+// matchesCategory accepts the exact category or any of its subcategories, so "email" matches "email.arrived"
+func matchesCategory(category string, notification *schema.Notification) bool {
+	actual, _, _ := notification.Hints.Category()
+	return actual == category || strings.HasPrefix(actual, category+".")
+}
+
+// apply changes the notification according to the actions of the rule
+func (rule *Rule) apply(notification *schema.Notification, result *Result) {
+	result.Matched = append(result.Matched, rule.Name)
+	result.Suppress = result.Suppress || rule.Suppress
+	result.SkipHistory = result.SkipHistory || rule.SkipHistory
+	result.Classes = append(result.Classes, rule.Classes...)
+	result.Mirror = result.Mirror || rule.Mirror
+
+	if rule.Timeout != nil {
+		notification.ExpireTimeout = *rule.Timeout
+	}
+	if rule.SetUrgency != nil {
+		notification.Urgency = *rule.SetUrgency
+	}
+	if rule.Icon != "" {
+		setIcon(notification, rule.Icon)
+	}
+	if rule.RewriteSummary != nil {
+		notification.Summary = rewrite(rule.Summary, notification.Summary, *rule.RewriteSummary)
+	}
+	if rule.RewriteBody != nil {
+		notification.Body = rewrite(rule.Body, notification.Body, *rule.RewriteBody)
+	}
+}
+
+// rewrite replaces the matches of pattern with the template, which may refer to the groups as in $1.
+// Without a pattern the whole text is replaced
+func rewrite(pattern *regexp.Regexp, text string, template string) string {
+	if pattern == nil {
+		return template
+	}
+	return pattern.ReplaceAllString(text, template)
+}
+
+// setIcon replaces the app icon and drops the images sent along with the notification
+func setIcon(notification *schema.Notification, icon string) {
+	hints := schema.Hints{}
+	for key, value := range notification.Hints {
+		switch key {
+		case schema.HintImageData, schema.HintImageDataDeprecated, schema.HintImagePath, schema.HintImagePathDeprecated,
+			schema.HintIconDataDeprecated:
+		default:
+			hints[key] = value
+		}
+	}
+	notification.Hints = hints
+	notification.AppIcon = icon
+}
+
+// Apply runs every matching rule, in order, on the notification
+func Apply(rules []*Rule, notification *schema.Notification, sender Sender) Result {
+	var result Result
+	for _, rule := range rules {
+		if rule.Matches(notification, sender) {
+			rule.apply(notification, &result)
+		}
+	}
+	return result
+}
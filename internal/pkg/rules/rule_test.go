@@ -0,0 +1,116 @@
+package rules
+
+import (
+	"testing"
+
+	"github.com/ahirata/notifyme/pkg/notifyme/schema"
+	"github.com/godbus/dbus"
+)
+
+type sender struct{ busName, processName string }
+
+func (sender sender) BusName() string     { return sender.busName }
+func (sender sender) ProcessName() string { return sender.processName }
+
+func rule(t *testing.T, name string, settings ...string) *Rule {
+	t.Helper()
+	rule := RuleNew(name)
+	for i := 0; i < len(settings); i += 2 {
+		if err := rule.Set(settings[i], settings[i+1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rule
+}
+
+func TestMatches(t *testing.T) {
+	notification := &schema.Notification{
+		AppName: "Thunderbird",
+		Summary: "New mail from Alice",
+		Body:    "Lunch?",
+		Hints:   schema.Hints{"category": dbus.MakeVariant("email.arrived")},
+		Urgency: schema.Normal,
+	}
+	client := sender{":1.42", "thunderbird"}
+
+	cases := []struct {
+		rule    *Rule
+		matches bool
+	}{
+		{rule(t, "everything"), true},
+		{rule(t, "app", "app-name", "Thunderbird"), true},
+		{rule(t, "other app", "app-name", "Slack"), false},
+		{rule(t, "summary", "summary", "^New mail"), true},
+		{rule(t, "body", "body", "(?i)dinner"), false},
+		{rule(t, "category", "category", "email"), true},
+		{rule(t, "category prefix", "category", "email.arr"), false},
+		{rule(t, "urgency", "urgency", "critical"), false},
+		{rule(t, "process", "sender", "thunderbird"), true},
+		{rule(t, "bus name", "sender", ":1.42"), true},
+		{rule(t, "all", "app-name", "Thunderbird", "summary", "Alice", "urgency", "normal"), true},
+	}
+	for _, c := range cases {
+		if matches := c.rule.Matches(notification, client); matches != c.matches {
+			t.Errorf("rule %q: expected %t, got %t", c.rule.Name, c.matches, matches)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	notification := &schema.Notification{
+		AppName:       "pager",
+		Summary:       "PAGE: database down",
+		ExpireTimeout: 10000,
+		Hints:         schema.Hints{"image-path": dbus.MakeVariant("/tmp/image.png")},
+	}
+	rules := []*Rule{
+		rule(t, "sticky", "app-name", "pager", "set-timeout", "0", "set-urgency", "critical", "add-class", "pager loud"),
+		rule(t, "strip", "summary", "^PAGE: (.*)$", "rewrite-summary", "$1", "set-icon", "dialog-warning"),
+		rule(t, "unmatched", "app-name", "other", "suppress", "true"),
+		rule(t, "critical", "urgency", "critical", "skip-history", "true"),
+	}
+
+	result := Apply(rules, notification, nil)
+
+	if len(result.Matched) != 3 || result.Suppress || !result.SkipHistory {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if len(result.Classes) != 2 || result.Classes[0] != "pager" {
+		t.Fatalf("unexpected classes %v", result.Classes)
+	}
+	if notification.ExpireTimeout != 0 || notification.Urgency != schema.Critical || notification.Summary != "database down" {
+		t.Fatalf("unexpected notification %+v", notification)
+	}
+	if _, found := notification.ImagePath(); found || notification.AppIcon != "dialog-warning" {
+		t.Fatalf("icon was not replaced: %+v", notification)
+	}
+}
+
+func TestSetIconDropsIconData(t *testing.T) {
+	iconData := []interface{}{int32(1), int32(1), int32(3), false, int32(8), int32(3), []byte{0, 0, 0}}
+	notification := &schema.Notification{
+		AppName: "legacy",
+		Hints:   schema.Hints{"icon_data": dbus.MakeVariant(iconData)},
+	}
+
+	Apply([]*Rule{rule(t, "icon", "set-icon", "dialog-warning")}, notification, nil)
+
+	if _, found := notification.LegacyIconData(); found || notification.AppIcon != "dialog-warning" {
+		t.Fatalf("icon data was not dropped: %+v", notification)
+	}
+}
+
+func TestSetRejectsInvalidValues(t *testing.T) {
+	invalid := [][2]string{
+		{"summary", "("},
+		{"urgency", "urgent"},
+		{"set-timeout", "-1"},
+		{"suppress", "maybe"},
+		{"colour", "red"},
+	}
+	for _, setting := range invalid {
+		if err := RuleNew("invalid").Set(setting[0], setting[1]); err == nil {
+			t.Errorf("expected an error for %s = %s", setting[0], setting[1])
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package scheduler
+
+import (
+	"container/heap"
+	"time"
+)
+
+// Scheduler keeps the expiration deadlines of the notifications in a min-heap, with a single timer set to the earliest.
+// When it goes off, wake is called and should collect the expired notifications with Expire.
+// A Scheduler is not safe for concurrent use: the owner must serialize the calls, wake included
+type Scheduler struct {
+	items     map[uint32]*item
+	deadlines deadlines
+	timer     *time.Timer
+	armed     time.Time
+	wake      func()
+	now       func() time.Time
+}
+
+// item is the timer of a notification. Paused items keep their remaining time and are out of the heap
+type item struct {
+	id        uint32
+	deadline  time.Time
+	remaining time.Duration
+	index     int
+}
+
+func (item *item) paused() bool {
+	return item.index < 0
+}
+
+// SchedulerNew creates an empty scheduler calling wake whenever a deadline is reached
+func SchedulerNew(wake func()) *Scheduler {
+	return &Scheduler{items: map[uint32]*item{}, wake: wake, now: time.Now}
+}
+
+// Schedule sets the timer of the notification to timeout, replacing any previous one. A paused timer only
+// starts counting once resumed
+func (scheduler *Scheduler) Schedule(id uint32, timeout time.Duration, paused bool) {
+	scheduler.remove(id)
+	item := &item{id: id, remaining: timeout, index: -1}
+	scheduler.items[id] = item
+	if !paused {
+		scheduler.start(item, 0)
+	}
+	scheduler.arm()
+}
+
+// Cancel drops the timer of the notification, if any
+func (scheduler *Scheduler) Cancel(id uint32) {
+	scheduler.remove(id)
+	scheduler.arm()
+}
+
+// Pause stops the timer of the notification, keeping the time left
+func (scheduler *Scheduler) Pause(id uint32) {
+	item, found := scheduler.items[id]
+	if !found || item.paused() {
+		return
+	}
+	heap.Remove(&scheduler.deadlines, item.index)
+	if item.remaining = item.deadline.Sub(scheduler.now()); item.remaining < 0 {
+		item.remaining = 0
+	}
+	scheduler.arm()
+}
+
+// Resume restarts a paused timer with the time left, but no less than minimum
+func (scheduler *Scheduler) Resume(id uint32, minimum time.Duration) {
+	item, found := scheduler.items[id]
+	if !found || !item.paused() {
+		return
+	}
+	scheduler.start(item, minimum)
+	scheduler.arm()
+}
+
+// Remaining returns the time left to the notification, and false if it has no timer
+func (scheduler *Scheduler) Remaining(id uint32) (time.Duration, bool) {
+	item, found := scheduler.items[id]
+	if !found {
+		return 0, false
+	}
+	if item.paused() {
+		return item.remaining, true
+	}
+	return item.deadline.Sub(scheduler.now()), true
+}
+
+// Len returns the number of timers, paused ones included
+func (scheduler *Scheduler) Len() int {
+	return len(scheduler.items)
+}
+
+// Expire removes and returns the notifications whose deadline was reached, the earliest first
+func (scheduler *Scheduler) Expire() []uint32 {
+	var expired []uint32
+	now := scheduler.now()
+	for len(scheduler.deadlines) > 0 && !scheduler.deadlines[0].deadline.After(now) {
+		item := heap.Pop(&scheduler.deadlines).(*item)
+		delete(scheduler.items, item.id)
+		expired = append(expired, item.id)
+	}
+	scheduler.arm()
+	return expired
+}
+
+// Stop drops every timer
+func (scheduler *Scheduler) Stop() {
+	scheduler.items = map[uint32]*item{}
+	scheduler.deadlines = nil
+	scheduler.arm()
+}
+
+func (scheduler *Scheduler) start(item *item, minimum time.Duration) {
+	if item.remaining < minimum {
+		item.remaining = minimum
+	}
+	item.deadline = scheduler.now().Add(item.remaining)
+	heap.Push(&scheduler.deadlines, item)
+}
+
+func (scheduler *Scheduler) remove(id uint32) {
+	item, found := scheduler.items[id]
+	if !found {
+		return
+	}
+	if !item.paused() {
+		heap.Remove(&scheduler.deadlines, item.index)
+	}
+	delete(scheduler.items, id)
+}
+
+// arm sets the timer to the earliest deadline, or stops it if there is none
+func (scheduler *Scheduler) arm() {
+	if len(scheduler.deadlines) == 0 {
+		if scheduler.timer != nil {
+			scheduler.timer.Stop()
+		}
+		scheduler.armed = time.Time{}
+		return
+	}
+
+	earliest := scheduler.deadlines[0].deadline
+	if earliest.Equal(scheduler.armed) {
+		return
+	}
+	scheduler.armed = earliest
+	delay := earliest.Sub(scheduler.now())
+	if scheduler.timer == nil {
+		scheduler.timer = time.AfterFunc(delay, scheduler.wake)
+	} else {
+		scheduler.timer.Reset(delay)
+	}
+}
+
+// deadlines implements heap.Interface, ordering the running items by deadline
+type deadlines []*item
+
+func (deadlines deadlines) Len() int {
+	return len(deadlines)
+}
+
+func (deadlines deadlines) Less(i, j int) bool {
+	return deadlines[i].deadline.Before(deadlines[j].deadline)
+}
+
+func (deadlines deadlines) Swap(i, j int) {
+	deadlines[i], deadlines[j] = deadlines[j], deadlines[i]
+	deadlines[i].index = i
+	deadlines[j].index = j
+}
+
+func (deadlines *deadlines) Push(value interface{}) {
+	item := value.(*item)
+	item.index = len(*deadlines)
+	*deadlines = append(*deadlines, item)
+}
+
+func (deadlines *deadlines) Pop() interface{} {
+	old := *deadlines
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	item.index = -1
+	*deadlines = old[:len(old)-1]
+	return item
+}
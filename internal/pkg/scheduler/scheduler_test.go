@@ -0,0 +1,173 @@
+package scheduler
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// clock is a fake time source moved by hand
+type clock struct {
+	current time.Time
+}
+
+func (clock *clock) now() time.Time {
+	return clock.current
+}
+
+func (clock *clock) advance(duration time.Duration) {
+	clock.current = clock.current.Add(duration)
+}
+
+func newTestScheduler() (*Scheduler, *clock) {
+	clock := &clock{current: time.Unix(1000, 0)}
+	scheduler := SchedulerNew(func() {})
+	scheduler.now = clock.now
+	return scheduler, clock
+}
+
+func TestExpireInDeadlineOrder(t *testing.T) {
+	scheduler, clock := newTestScheduler()
+	scheduler.Schedule(1, 30*time.Second, false)
+	scheduler.Schedule(2, 10*time.Second, false)
+	scheduler.Schedule(3, 20*time.Second, false)
+
+	if expired := scheduler.Expire(); len(expired) != 0 {
+		t.Fatalf("nothing should expire yet, got %v", expired)
+	}
+	clock.advance(25 * time.Second)
+	if expired := scheduler.Expire(); !reflect.DeepEqual(expired, []uint32{2, 3}) {
+		t.Fatalf("expected [2 3], got %v", expired)
+	}
+	if scheduler.Len() != 1 {
+		t.Fatalf("expected 1 timer left, got %d", scheduler.Len())
+	}
+}
+
+func TestCancelAndReschedule(t *testing.T) {
+	scheduler, clock := newTestScheduler()
+	scheduler.Schedule(1, 10*time.Second, false)
+	scheduler.Schedule(2, 10*time.Second, false)
+	scheduler.Cancel(1)
+	scheduler.Cancel(42)
+	scheduler.Schedule(2, time.Minute, false)
+
+	clock.advance(30 * time.Second)
+	if expired := scheduler.Expire(); len(expired) != 0 {
+		t.Fatalf("expected nothing to expire, got %v", expired)
+	}
+	if remaining, found := scheduler.Remaining(2); !found || remaining != 30*time.Second {
+		t.Fatalf("expected 30s left, got %v", remaining)
+	}
+}
+
+func TestPauseAndResume(t *testing.T) {
+	scheduler, clock := newTestScheduler()
+	scheduler.Schedule(1, 10*time.Second, false)
+	scheduler.Schedule(2, 10*time.Second, true)
+
+	clock.advance(8 * time.Second)
+	scheduler.Pause(1)
+	clock.advance(time.Hour)
+	if expired := scheduler.Expire(); len(expired) != 0 {
+		t.Fatalf("paused timers must not expire, got %v", expired)
+	}
+
+	scheduler.Resume(1, 5*time.Second)
+	scheduler.Resume(2, 0)
+	if remaining, _ := scheduler.Remaining(1); remaining != 5*time.Second {
+		t.Fatalf("expected the minimum of 5s, got %v", remaining)
+	}
+	if remaining, _ := scheduler.Remaining(2); remaining != 10*time.Second {
+		t.Fatalf("expected the full 10s, got %v", remaining)
+	}
+
+	clock.advance(10 * time.Second)
+	if expired := scheduler.Expire(); !reflect.DeepEqual(expired, []uint32{1, 2}) {
+		t.Fatalf("expected [1 2], got %v", expired)
+	}
+}
+
+func TestTimerWakesUp(t *testing.T) {
+	woken := make(chan struct{}, 10)
+	scheduler := SchedulerNew(func() { woken <- struct{}{} })
+	scheduler.Schedule(1, time.Hour, false)
+	scheduler.Schedule(2, 10*time.Millisecond, false)
+
+	select {
+	case <-woken:
+	case <-time.After(time.Second):
+		t.Fatal("the scheduler did not wake up")
+	}
+	if expired := scheduler.Expire(); !reflect.DeepEqual(expired, []uint32{2}) {
+		t.Fatalf("expected [2], got %v", expired)
+	}
+	scheduler.Stop()
+	if scheduler.Len() != 0 {
+		t.Fatal("expected no timers after Stop")
+	}
+}
+
+// pending fills a scheduler with timers spread over an hour
+func pending(count int) (*Scheduler, *clock) {
+	scheduler, clock := newTestScheduler()
+	for id := 1; id <= count; id++ {
+		scheduler.Schedule(uint32(id), time.Duration(1+id*7919%3600)*time.Second, false)
+	}
+	return scheduler, clock
+}
+
+func BenchmarkSchedule(b *testing.B) {
+	for _, count := range []int{1000, 10000, 50000} {
+		b.Run(strconv.Itoa(count), func(b *testing.B) {
+			scheduler, _ := pending(count)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				scheduler.Schedule(uint32(count+1+i%count), time.Duration(i%3600)*time.Second, false)
+			}
+		})
+	}
+}
+
+func BenchmarkReschedule(b *testing.B) {
+	for _, count := range []int{1000, 10000, 50000} {
+		b.Run(strconv.Itoa(count), func(b *testing.B) {
+			scheduler, _ := pending(count)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				scheduler.Schedule(uint32(1+i%count), time.Duration(i%3600)*time.Second, false)
+			}
+		})
+	}
+}
+
+func BenchmarkPauseResume(b *testing.B) {
+	for _, count := range []int{1000, 10000, 50000} {
+		b.Run(strconv.Itoa(count), func(b *testing.B) {
+			scheduler, _ := pending(count)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				id := uint32(1 + i%count)
+				scheduler.Pause(id)
+				scheduler.Resume(id, 0)
+			}
+		})
+	}
+}
+
+func BenchmarkExpire(b *testing.B) {
+	for _, count := range []int{1000, 10000, 50000} {
+		b.Run(strconv.Itoa(count), func(b *testing.B) {
+			scheduler, _ := pending(count)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				id := uint32(count + 1 + i%count)
+				scheduler.Schedule(id, -time.Second, false)
+				if expired := scheduler.Expire(); len(expired) != 1 || expired[0] != id {
+					b.Fatalf("expected [%d], got %v", id, expired)
+				}
+			}
+		})
+	}
+}
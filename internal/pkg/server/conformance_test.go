@@ -0,0 +1,620 @@
+package notifyme
+
+import (
+	"bufio"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ahirata/notifyme/internal/pkg/config"
+	"github.com/ahirata/notifyme/internal/pkg/history"
+	"github.com/ahirata/notifyme/internal/pkg/render"
+	"github.com/ahirata/notifyme/pkg/notifyme/schema"
+	"github.com/godbus/dbus"
+	"github.com/godbus/dbus/introspect"
+)
+
+// conformance runs a server with a headless renderer on a private session bus,
+// and talks to it through a separate client connection
+type conformance struct {
+	t        *testing.T
+	address  string
+	server   *Server
+	renderer *render.Headless
+	client   *dbus.Conn
+	object   dbus.BusObject
+	signals  orderedSignals
+}
+
+// startBus launches a private dbus-daemon and returns its address
+func startBus(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+
+	socket := filepath.Join(t.TempDir(), "bus")
+	daemon := exec.Command("dbus-daemon", "--session", "--nofork", "--print-address=1", "--address=unix:path="+socket)
+	stdout, err := daemon.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := daemon.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		daemon.Process.Kill()
+		daemon.Wait()
+	})
+
+	address, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil {
+		t.Fatalf("reading bus address: %v", err)
+	}
+	return strings.TrimSpace(address)
+}
+
+// orderedSignals delivers the notification signals in the order they were received,
+// which the default signal handler does not guarantee
+type orderedSignals chan *dbus.Signal
+
+func (signals orderedSignals) DeliverSignal(iface, name string, signal *dbus.Signal) {
+	if iface == serviceInterface {
+		signals <- signal
+	}
+}
+
+func dial(t *testing.T, address string, signalHandler dbus.SignalHandler) *dbus.Conn {
+	t.Helper()
+	conn, err := dbus.DialHandler(address, dbus.NewDefaultHandler(), signalHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Auth(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Hello(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func newConformance(t *testing.T) *conformance {
+	address := startBus(t)
+
+	notificationHistory, err := history.HistoryNew(t.TempDir(), 100, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renderer := render.HeadlessNew()
+	server := ServerNew(renderer, notificationHistory)
+	serverConn := dial(t, address, dbus.NewDefaultSignalHandler())
+	served := make(chan error, 1)
+	go func() { served <- server.Serve(serverConn, false) }()
+
+	signals := make(orderedSignals, 100)
+	client := dial(t, address, signals)
+	match := "type='signal',interface='" + serviceInterface + "'"
+	if call := client.BusObject().Call("org.freedesktop.DBus.AddMatch", 0, match); call.Err != nil {
+		t.Fatal(call.Err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var owned bool
+		client.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, serviceInterface).Store(&owned)
+		if owned {
+			break
+		}
+		select {
+		case err := <-served:
+			t.Fatalf("server stopped: %v", err)
+		default:
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("server did not take the service name")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return &conformance{
+		t:        t,
+		address:  address,
+		server:   server,
+		renderer: renderer,
+		client:   client,
+		object:   client.Object(serviceInterface, objectPath),
+		signals:  signals,
+	}
+}
+
+// call calls the method on the standard interface, or on the vendor one if it is not standard
+func (c *conformance) call(method string, args ...interface{}) *dbus.Call {
+	c.t.Helper()
+	if _, vendor := c.server.vendorCommands()[method]; vendor {
+		return c.object.Call(vendorInterface+"."+method, 0, args...)
+	}
+	return c.object.Call(serviceInterface+"."+method, 0, args...)
+}
+
+func (c *conformance) notify(replacesID uint32, summary string, actions []string, hints map[string]dbus.Variant, expireTimeout int32) uint32 {
+	c.t.Helper()
+	if actions == nil {
+		actions = []string{}
+	}
+	if hints == nil {
+		hints = map[string]dbus.Variant{}
+	}
+
+	var id uint32
+	if err := c.call("Notify", "conformance", replacesID, "", summary, "body", actions, hints, expireTimeout).Store(&id); err != nil {
+		c.t.Fatalf("Notify failed: %v", err)
+	}
+	return id
+}
+
+// expectSignals waits for the given signals, in order, and then for silence
+func (c *conformance) expectSignals(expected ...interface{}) {
+	c.t.Helper()
+	for _, want := range expected {
+		select {
+		case signal := <-c.signals:
+			if got := decodeSignal(signal); got != want {
+				c.t.Fatalf("expected %+v, got %+v", want, got)
+			}
+		case <-time.After(2 * time.Second):
+			c.t.Fatalf("timed out waiting for %+v", want)
+		}
+	}
+	select {
+	case signal := <-c.signals:
+		c.t.Fatalf("unexpected signal %+v", decodeSignal(signal))
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// waitVisible waits for the number of popups to settle, since calls are asynchronous to signals
+func (c *conformance) waitVisible(expected int) {
+	c.t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for len(c.renderer.Visible()) != expected {
+		if time.Now().After(deadline) {
+			c.t.Fatalf("expected %d popups, got %d", expected, len(c.renderer.Visible()))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func decodeSignal(signal *dbus.Signal) interface{} {
+	switch signal.Name {
+	case notificationClosedSignal:
+		return schema.NotificationClosed{ID: signal.Body[0].(uint32), Reason: signal.Body[1].(uint32)}
+	case actionInvokedSignal:
+		return schema.ActionInvoked{ID: signal.Body[0].(uint32), ActionKey: signal.Body[1].(string)}
+	}
+	return signal.Name
+}
+
+// conformanceCases has a case for every method exported by the server
+var conformanceCases = map[string]func(c *conformance){
+	"GetServerInformation": func(c *conformance) {
+		var name, vendor, version, specVersion string
+		if err := c.call("GetServerInformation").Store(&name, &vendor, &version, &specVersion); err != nil {
+			c.t.Fatal(err)
+		}
+		if name != "notifyme" || specVersion != "1.2" {
+			c.t.Fatalf("unexpected information %s %s %s %s", name, vendor, version, specVersion)
+		}
+	},
+	"GetCapabilities": func(c *conformance) {
+		var capabilities []string
+		if err := c.call("GetCapabilities").Store(&capabilities); err != nil {
+			c.t.Fatal(err)
+		}
+		for _, required := range []string{"body", "actions"} {
+			found := false
+			for _, capability := range capabilities {
+				found = found || capability == required
+			}
+			if !found {
+				c.t.Fatalf("capability %q missing from %v", required, capabilities)
+			}
+		}
+	},
+	"Notify": func(c *conformance) {
+		first := c.notify(0, "first", nil, nil, 0)
+		second := c.notify(0, "second", nil, nil, 0)
+		if first == 0 || second == 0 || first == second {
+			c.t.Fatalf("expected distinct non-zero ids, got %d and %d", first, second)
+		}
+
+		if replaced := c.notify(first, "replaced", nil, nil, 0); replaced != first {
+			c.t.Fatalf("replaces_id %d returned %d", first, replaced)
+		}
+		c.waitVisible(2)
+		if popup, _ := c.renderer.Get(first); popup.Notification.Summary != "replaced" {
+			c.t.Fatalf("popup was not replaced: %+v", popup.Notification)
+		}
+
+		expiring := c.notify(0, "expiring", nil, nil, 50)
+		c.expectSignals(schema.NotificationClosed{ID: expiring, Reason: schema.Expired})
+
+		call := c.call("Notify", "conformance", uint32(0), "", "odd", "body", []string{"default"}, map[string]dbus.Variant{}, int32(0))
+		if err, ok := call.Err.(dbus.Error); !ok || err.Name != invalidArgsError {
+			c.t.Fatalf("expected %s, got %v", invalidArgsError, call.Err)
+		}
+	},
+	"CloseNotification": func(c *conformance) {
+		id := c.notify(0, "closing", nil, nil, 0)
+		if err := c.call("CloseNotification", id).Err; err != nil {
+			c.t.Fatal(err)
+		}
+		c.expectSignals(schema.NotificationClosed{ID: id, Reason: schema.Closed})
+
+		c.call("CloseNotification", id)
+		c.expectSignals()
+	},
+	"CloseLastNotification": func(c *conformance) {
+		first := c.notify(0, "first", nil, nil, 0)
+		last := c.notify(0, "last", nil, nil, 0)
+		c.call("CloseLastNotification")
+		c.expectSignals(schema.NotificationClosed{ID: last, Reason: schema.Dismissed})
+		c.call("CloseLastNotification")
+		c.expectSignals(schema.NotificationClosed{ID: first, Reason: schema.Dismissed})
+		c.call("CloseLastNotification")
+		c.expectSignals()
+	},
+	"OpenLastNotification": func(c *conformance) {
+		id := c.notify(0, "open", []string{"default", "Open"}, nil, 0)
+		c.call("OpenLastNotification")
+		c.expectSignals(
+			schema.ActionInvoked{ID: id, ActionKey: "default"},
+			schema.NotificationClosed{ID: id, Reason: schema.Dismissed},
+		)
+	},
+	"ToggleMute": func(c *conformance) {
+		c.call("ToggleMute")
+		muted := c.notify(0, "muted", nil, nil, 0)
+		c.expectSignals(schema.NotificationClosed{ID: muted, Reason: schema.Undefined})
+
+		// the summary of the missed notification comes along
+		c.call("ToggleMute")
+		c.notify(0, "unmuted", nil, nil, 0)
+		c.waitVisible(2)
+	},
+	"SetDoNotDisturb": func(c *conformance) {
+		c.call("SetDoNotDisturb", "on", time.Now().Add(time.Hour).Unix())
+		held := c.notify(0, "held", nil, nil, 0)
+		c.expectSignals(schema.NotificationClosed{ID: held, Reason: schema.Undefined})
+
+		call := c.call("SetDoNotDisturb", "sometimes", int64(0))
+		if err, ok := call.Err.(dbus.Error); !ok || err.Name != invalidArgsError {
+			c.t.Fatalf("expected %s, got %v", invalidArgsError, call.Err)
+		}
+
+		c.call("SetDoNotDisturb", "auto", int64(0))
+		c.notify(0, "shown", nil, nil, 0)
+		c.waitVisible(2)
+	},
+	"GetDoNotDisturb": func(c *conformance) {
+		until := time.Now().Add(time.Hour).Unix()
+		c.call("SetDoNotDisturb", "on", until)
+
+		var mode string
+		var gotUntil int64
+		var active bool
+		if err := c.call("GetDoNotDisturb").Store(&mode, &gotUntil, &active); err != nil {
+			c.t.Fatal(err)
+		}
+		if mode != "on" || gotUntil != until || !active {
+			c.t.Fatalf("unexpected state %s until %d, active %t", mode, gotUntil, active)
+		}
+	},
+	"ListHeld": func(c *conformance) {
+		c.notify(0, "shown", nil, nil, 0)
+		c.call("SetDoNotDisturb", "on", int64(0))
+		held := c.notify(0, "held", nil, nil, 0)
+		c.expectSignals(schema.NotificationClosed{ID: held, Reason: schema.Undefined})
+
+		var entries []history.Entry
+		if err := c.call("ListHeld", uint32(0), uint32(0)).Store(&entries); err != nil {
+			c.t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].NotificationID != held {
+			c.t.Fatalf("unexpected held notifications %+v", entries)
+		}
+	},
+	"ListHistory": func(c *conformance) {
+		c.notify(0, "first", nil, nil, 0)
+		c.notify(0, "second", nil, nil, 0)
+		c.notify(0, "transient", nil, map[string]dbus.Variant{"transient": dbus.MakeVariant(true)}, 0)
+
+		var entries []history.Entry
+		if err := c.call("ListHistory", uint32(0), uint32(0)).Store(&entries); err != nil {
+			c.t.Fatal(err)
+		}
+		if len(entries) != 2 || entries[0].Summary != "second" || entries[1].Summary != "first" {
+			c.t.Fatalf("unexpected history %+v", entries)
+		}
+
+		var page []history.Entry
+		if err := c.call("ListHistory", uint32(1), uint32(1)).Store(&page); err != nil {
+			c.t.Fatal(err)
+		}
+		if len(page) != 1 || page[0].Summary != "first" {
+			c.t.Fatalf("unexpected page %+v", page)
+		}
+	},
+	"SearchHistory": func(c *conformance) {
+		c.notify(0, "Build failed", nil, nil, 0)
+		c.notify(0, "New mail", nil, nil, 0)
+
+		var entries []history.Entry
+		if err := c.call("SearchHistory", "build", uint32(0)).Store(&entries); err != nil {
+			c.t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].Summary != "Build failed" {
+			c.t.Fatalf("unexpected search result %+v", entries)
+		}
+	},
+	"GetHistoryEntry": func(c *conformance) {
+		id := c.notify(0, "kept", nil, nil, 0)
+
+		var entries []history.Entry
+		if err := c.call("ListHistory", uint32(0), uint32(1)).Store(&entries); err != nil || len(entries) != 1 {
+			c.t.Fatalf("unexpected history %+v: %v", entries, err)
+		}
+
+		var entry history.Entry
+		if err := c.call("GetHistoryEntry", entries[0].ID).Store(&entry); err != nil {
+			c.t.Fatal(err)
+		}
+		if entry.NotificationID != id || entry.Summary != "kept" {
+			c.t.Fatalf("unexpected entry %+v", entry)
+		}
+
+		call := c.call("GetHistoryEntry", entries[0].ID+1)
+		if err, ok := call.Err.(dbus.Error); !ok || err.Name != notFoundError {
+			c.t.Fatalf("expected %s, got %v", notFoundError, call.Err)
+		}
+	},
+	"ListSuppressed": func(c *conformance) {
+		configuration := config.Default()
+		configuration.AppRate = 0.001
+		configuration.AppBurst = 1
+		configuration.Excess = config.ExcessHistory
+		c.server.Configure(configuration)
+
+		c.notify(0, "allowed", nil, nil, 0)
+		first := c.notify(0, "flood", nil, nil, 0)
+		second := c.notify(0, "flood", nil, nil, 0)
+		c.expectSignals(
+			schema.NotificationClosed{ID: first, Reason: schema.Undefined},
+			schema.NotificationClosed{ID: second, Reason: schema.Undefined},
+		)
+
+		var suppressions []schema.Suppression
+		if err := c.call("ListSuppressed").Store(&suppressions); err != nil {
+			c.t.Fatal(err)
+		}
+		expected := []schema.Suppression{{Kind: "app", Name: "conformance", Count: 2}}
+		if !reflect.DeepEqual(suppressions, expected) {
+			c.t.Fatalf("expected %+v, got %+v", expected, suppressions)
+		}
+	},
+	"Kill": func(c *conformance) {
+		id := c.notify(0, "remaining", nil, nil, 0)
+		c.call("Kill")
+		c.expectSignals(schema.NotificationClosed{ID: id, Reason: schema.Undefined})
+		select {
+		case <-c.renderer.Done():
+		case <-time.After(time.Second):
+			c.t.Fatal("renderer was not stopped")
+		}
+	},
+}
+
+func TestConformanceCoversAllCommands(t *testing.T) {
+	var commands, cases []string
+	server := ServerNew(render.HeadlessNew(), nil)
+	for _, table := range []map[string]interface{}{server.commands(), server.vendorCommands()} {
+		for method := range table {
+			commands = append(commands, method)
+		}
+	}
+	for method := range conformanceCases {
+		cases = append(cases, method)
+	}
+	if !sameElements(commands, cases) {
+		t.Fatalf("commands %v do not match the conformance cases %v", commands, cases)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	for method, run := range conformanceCases {
+		run := run
+		t.Run(method, func(t *testing.T) {
+			run(newConformance(t))
+		})
+	}
+}
+
+func TestConformanceDeprecatedAliases(t *testing.T) {
+	c := newConformance(t)
+
+	id := c.notify(0, "last", nil, nil, 0)
+	if err := c.object.Call(serviceInterface+".CloseLastNotification", 0).Err; err != nil {
+		t.Fatal(err)
+	}
+	c.expectSignals(schema.NotificationClosed{ID: id, Reason: schema.Dismissed})
+
+	call := c.object.Call(vendorInterface+".Notify", 0, "conformance", uint32(0), "", "standard", "body", []string{}, map[string]dbus.Variant{}, int32(0))
+	if call.Err == nil {
+		t.Fatal("the standard methods should not be on the vendor interface")
+	}
+}
+
+func TestConformanceIntrospection(t *testing.T) {
+	c := newConformance(t)
+
+	node, err := introspect.Call(c.object)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interfaces := map[string]introspect.Interface{}
+	for _, iface := range node.Interfaces {
+		interfaces[iface.Name] = iface
+	}
+	for _, name := range []string{introspectableInterface, "org.freedesktop.DBus.Properties", serviceInterface, vendorInterface} {
+		if _, found := interfaces[name]; !found {
+			t.Fatalf("interface %s is missing from %+v", name, node.Interfaces)
+		}
+	}
+
+	signature := func(iface string, name string) (string, bool) {
+		for _, method := range interfaces[iface].Methods {
+			if method.Name == name {
+				var types []string
+				for _, arg := range method.Args {
+					types = append(types, arg.Direction+" "+arg.Name+" "+arg.Type)
+				}
+				return strings.Join(types, ", "), len(method.Annotations) > 0
+			}
+		}
+		t.Fatalf("method %s.%s is missing", iface, name)
+		return "", false
+	}
+	expected := "in app_name s, in replaces_id u, in app_icon s, in summary s, in body s, in actions as, in hints a{sv}, in expire_timeout i, out id u"
+	if notify, deprecated := signature(serviceInterface, "Notify"); notify != expected || deprecated {
+		t.Fatalf("unexpected Notify %q", notify)
+	}
+	if _, deprecated := signature(serviceInterface, "Kill"); !deprecated {
+		t.Fatal("the aliases on the standard interface should be deprecated")
+	}
+	if kill, deprecated := signature(vendorInterface, "Kill"); kill != "" || deprecated {
+		t.Fatalf("unexpected Kill %q", kill)
+	}
+	if len(interfaces[vendorInterface].Properties) != len(Status{}.properties()) {
+		t.Fatalf("unexpected properties %+v", interfaces[vendorInterface].Properties)
+	}
+
+	root, err := introspect.Call(c.client.Object(serviceInterface, "/org/freedesktop"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(root.Children) != 1 || root.Children[0].Name != "Notifications" {
+		t.Fatalf("unexpected children %+v", root.Children)
+	}
+}
+
+func TestConformanceReplace(t *testing.T) {
+	c := newConformance(t)
+	id := c.notify(0, "replaced", nil, nil, 0)
+
+	taken := ServerNew(render.HeadlessNew(), nil)
+	if err := taken.Serve(dial(t, c.address, dbus.NewDefaultSignalHandler()), false); err == nil {
+		t.Fatal("expected an error taking the name without replacing it")
+	}
+
+	renderer := render.HeadlessNew()
+	replacement := ServerNew(renderer, nil)
+	go replacement.Serve(dial(t, c.address, dbus.NewDefaultSignalHandler()), true)
+
+	c.expectSignals(schema.NotificationClosed{ID: id, Reason: schema.Undefined})
+	select {
+	case <-c.renderer.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("the replaced server did not shut down")
+	}
+
+	c.notify(0, "new", nil, nil, 0)
+	if visible := renderer.Visible(); len(visible) != 1 || visible[0].Notification.Summary != "new" {
+		t.Fatalf("the replacement did not take the notification: %+v", visible)
+	}
+}
+
+// propertyChanges delivers the PropertiesChanged signals of the vendor interface
+type propertyChanges chan map[string]dbus.Variant
+
+func (changes propertyChanges) DeliverSignal(iface, name string, signal *dbus.Signal) {
+	if iface == "org.freedesktop.DBus.Properties" && name == "PropertiesChanged" && signal.Body[0] == vendorInterface {
+		changes <- signal.Body[1].(map[string]dbus.Variant)
+	}
+}
+
+func TestConformanceProperties(t *testing.T) {
+	c := newConformance(t)
+	changes := make(propertyChanges, 100)
+	watcher := dial(t, c.address, changes)
+	match := "type='signal',interface='org.freedesktop.DBus.Properties',member='PropertiesChanged'"
+	if call := watcher.BusObject().Call("org.freedesktop.DBus.AddMatch", 0, match); call.Err != nil {
+		t.Fatal(call.Err)
+	}
+
+	// the changes come in no particular order, so the last value of each property is kept
+	changed := map[string]interface{}{}
+	expectChange := func(name string, value interface{}) {
+		t.Helper()
+		timeout := time.After(2 * time.Second)
+		for changed[name] != value {
+			select {
+			case properties := <-changes:
+				for property, variant := range properties {
+					changed[property] = variant.Value()
+				}
+			case <-timeout:
+				t.Fatalf("%s did not change to %v", name, value)
+			}
+		}
+	}
+
+	c.notify(0, "shown", nil, nil, 0)
+	expectChange("Visible", uint32(1))
+	expectChange("HistoryCount", uint32(1))
+
+	c.call("SetDoNotDisturb", "on", int64(0))
+	expectChange("Muted", true)
+	expectChange("DoNotDisturb", "on")
+
+	var properties map[string]dbus.Variant
+	call := c.client.Object(serviceInterface, objectPath).Call("org.freedesktop.DBus.Properties.GetAll", 0, vendorInterface)
+	if err := call.Store(&properties); err != nil {
+		t.Fatal(err)
+	}
+	if properties["Muted"].Value() != true || properties["Visible"].Value() != uint32(1) || properties["Queued"].Value() != uint32(0) {
+		t.Fatalf("unexpected properties %v", properties)
+	}
+}
+
+func TestConformanceActionSequence(t *testing.T) {
+	c := newConformance(t)
+
+	id := c.notify(0, "actions", []string{"default", "Open", "reply", "Reply"}, nil, 0)
+	c.waitVisible(1)
+	c.renderer.InvokeAction(id, "reply")
+	c.expectSignals(
+		schema.ActionInvoked{ID: id, ActionKey: "reply"},
+		schema.NotificationClosed{ID: id, Reason: schema.Dismissed},
+	)
+
+	dismissed := c.notify(0, "dismissed", nil, nil, 0)
+	c.waitVisible(1)
+	c.renderer.Dismiss(dismissed)
+	c.expectSignals(schema.NotificationClosed{ID: dismissed, Reason: schema.Dismissed})
+}
+
+func sameElements(a, b []string) bool {
+	set := func(values []string) map[string]bool {
+		result := make(map[string]bool)
+		for _, value := range values {
+			result[value] = true
+		}
+		return result
+	}
+	return len(a) == len(b) && reflect.DeepEqual(set(a), set(b))
+}
@@ -1,9 +1,11 @@
 package notifyme
 
 import (
+	"errors"
 	"fmt"
 	"github.com/ahirata/notifyme/pkg/notifyme/schema"
 	"github.com/godbus/dbus"
+	"github.com/godbus/dbus/prop"
 )
 
 const (
@@ -11,35 +13,77 @@ const (
 	serviceInterface         = "org.freedesktop.Notifications"
 	actionInvokedSignal      = serviceInterface + ".ActionInvoked"
 	notificationClosedSignal = serviceInterface + ".NotificationClosed"
-	killMethod               = serviceInterface + ".Kill"
+	invalidArgsError         = serviceInterface + ".Error.InvalidArgs"
+	notFoundError            = serviceInterface + ".Error.NotFound"
+	// vendorInterface holds the methods and properties specific to notifyme
+	vendorInterface = "io.github.ahirata.Notifyme"
+	killMethod      = vendorInterface + ".Kill"
+	nameLostSignal  = "org.freedesktop.DBus.NameLost"
 )
 
 // DbusHandler type struct
 type DbusHandler struct {
-	conn *dbus.Conn
+	conn    *dbus.Conn
+	props   *prop.Properties
+	signals chan *dbus.Signal
 }
 
-// DbusHandlerNew connects to dbus exporting the commands on methodTable
-func DbusHandlerNew(methodTable map[string]interface{}) *DbusHandler {
-	conn, err := dbus.SessionBus()
-	if err != nil {
-		panic(err)
+// DbusHandlerNew takes the service name on conn, exporting the standard methods of methodTable, the non-standard
+// ones of vendorTable and the status as properties, along with their introspection data. The name is taken over
+// from the running server if replace is true, and can always be taken over by the next one
+func DbusHandlerNew(conn *dbus.Conn, methodTable map[string]interface{}, vendorTable map[string]interface{}, status Status, replace bool) (*DbusHandler, error) {
+	// the non-standard methods stay on the standard interface as deprecated aliases, for the clients calling them there
+	aliases := make(map[string]interface{})
+	for _, table := range []map[string]interface{}{vendorTable, methodTable} {
+		for name, method := range table {
+			aliases[name] = method
+		}
 	}
 
-	reply, err := conn.RequestName(serviceInterface, dbus.NameFlagDoNotQueue)
+	// export before taking the name, so no call arrives before the methods are there
+	if err := conn.ExportMethodTable(aliases, objectPath, serviceInterface); err != nil {
+		return nil, err
+	}
+	if err := conn.ExportMethodTable(vendorTable, objectPath, vendorInterface); err != nil {
+		return nil, err
+	}
+	properties := map[string]*prop.Prop{}
+	for name, value := range status.properties() {
+		properties[name] = &prop.Prop{Value: value, Emit: prop.EmitTrue}
+	}
+	props := prop.New(conn, objectPath, map[string]map[string]*prop.Prop{vendorInterface: properties})
+	if err := exportIntrospection(conn, introspection(methodTable, vendorTable, props)); err != nil {
+		return nil, err
+	}
+
+	// listen before taking the name, so that losing it right away is not missed
+	signals := make(chan *dbus.Signal, 10)
+	conn.Signal(signals)
+
+	flags := dbus.NameFlagDoNotQueue | dbus.NameFlagAllowReplacement
+	if replace {
+		flags |= dbus.NameFlagReplaceExisting
+	}
+	reply, err := conn.RequestName(serviceInterface, flags)
 	if err != nil {
-		panic(err)
+		conn.RemoveSignal(signals)
+		return nil, err
 	}
 
-	if reply != dbus.RequestNameReplyPrimaryOwner {
-		panic("Name already taken")
+	if reply != dbus.RequestNameReplyPrimaryOwner && reply != dbus.RequestNameReplyAlreadyOwner {
+		conn.RemoveSignal(signals)
+		if replace {
+			return nil, errors.New("Name already taken by a server that does not allow replacing it")
+		}
+		return nil, errors.New("Name already taken, run with --replace to take it over")
 	}
 	fmt.Println("Connected to dbus")
-	conn.ExportMethodTable(methodTable, objectPath, serviceInterface)
 
 	return &DbusHandler{
-		conn: conn,
-	}
+		conn:    conn,
+		props:   props,
+		signals: signals,
+	}, nil
 }
 
 // KillServer calls Kill on the server
@@ -57,17 +101,46 @@ func (handler *DbusHandler) KillServer() {
 	}
 	obj := conn.Object(serviceInterface, objectPath)
 	call := obj.Call(killMethod, 0)
+	if err, ok := call.Err.(dbus.Error); ok && (err.Name == dbus.ErrMsgUnknownMethod.Name || err.Name == dbus.ErrMsgUnknownInterface.Name) {
+		// servers older than the vendor interface have Kill on the standard one
+		call = obj.Call(serviceInterface+".Kill", 0)
+	}
 	if call.Err != nil {
 		panic(call.Err)
 	}
 }
 
+// WatchNameLost calls lost once another server took the service name over
+func (handler *DbusHandler) WatchNameLost(lost func()) {
+	go func() {
+		// keep reading once the name is lost, since the connection blocks on the signals not read
+		notified := false
+		for signal := range handler.signals {
+			if notified || signal.Name != nameLostSignal || len(signal.Body) == 0 || signal.Body[0] != serviceInterface {
+				continue
+			}
+			notified = true
+			fmt.Println("Lost the name to another server")
+			lost()
+		}
+	}()
+}
+
+// SetStatus updates the properties that changed, emitting PropertiesChanged for each of them
+func (handler *DbusHandler) SetStatus(status Status) {
+	for name, value := range status.properties() {
+		if handler.props.GetMust(vendorInterface, name) != value {
+			handler.props.SetMust(vendorInterface, name, value)
+		}
+	}
+}
+
 // EmitNotificationClosed emits the NotificationClosed signal
 func (handler *DbusHandler) EmitNotificationClosed(notificationClosed schema.NotificationClosed) {
 	handler.conn.Emit(objectPath, notificationClosedSignal, notificationClosed.ID, notificationClosed.Reason)
 }
 
-// EmitActionInvoked emits the ActionInvoked signa
+// EmitActionInvoked emits the ActionInvoked signal
 func (handler *DbusHandler) EmitActionInvoked(actionInvoked schema.ActionInvoked) {
 	handler.conn.Emit(objectPath, actionInvokedSignal, actionInvoked.ID, actionInvoked.ActionKey)
 }
@@ -0,0 +1,48 @@
+package notifyme
+
+import (
+	"time"
+
+	"github.com/ahirata/notifyme/internal/pkg/store"
+	"github.com/ahirata/notifyme/pkg/notifyme/schema"
+)
+
+// maxMerged bounds the notifications merged into one, past which the oldest ones are closed
+const maxMerged = 99
+
+// The methods below require the server lock, like the ones in lifecycle.go
+
+// duplicate returns the open notification that the new one repeats within the configured window, if any.
+// Replacements are never duplicates
+func (server *Server) duplicate(notification *schema.Notification) *store.Entry {
+	if server.config.DedupWindow <= 0 || notification.ReplacesID != 0 {
+		return nil
+	}
+	return server.store.Duplicate(notification, time.Now().Add(-server.config.DedupWindow))
+}
+
+// merge counts the notification as a repeat of the entry, which keeps its popup and restarts its expiration.
+// The notification keeps its own id, and is closed along with the entry
+func (server *Server) merge(entry *store.Entry, notification *schema.Notification) {
+	for len(entry.Merged) >= maxMerged {
+		server.closeMerged(entry.Merged[0], schema.Undefined)
+	}
+	entry.Merged = append(entry.Merged, notification.ID)
+	entry.Updated = time.Now()
+	if entry.State == store.Shown {
+		server.renderer.Update(popup(entry))
+		server.scheduleExpiration(entry.Notification)
+	}
+}
+
+// unmerge detaches a merged notification from the entry it was merged into, returning false if it was not merged
+func (server *Server) unmerge(id uint32) bool {
+	entry := server.store.Owner(id)
+	if entry == nil || !entry.Unmerge(id) {
+		return false
+	}
+	if entry.State == store.Shown {
+		server.renderer.Update(popup(entry))
+	}
+	return true
+}
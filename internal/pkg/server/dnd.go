@@ -0,0 +1,87 @@
+package notifyme
+
+import (
+	"fmt"
+	"github.com/ahirata/notifyme/internal/pkg/dnd"
+	"github.com/ahirata/notifyme/internal/pkg/history"
+	"github.com/ahirata/notifyme/pkg/notifyme/schema"
+	"github.com/godbus/dbus"
+	"time"
+)
+
+// holds tells whether do not disturb keeps the notification from being shown
+func (server *Server) holds(notification *schema.Notification) bool {
+	server.checkDoNotDisturb()
+	return server.dnd.Active(server.config.QuietHours, time.Now()) && !server.config.DNDExceptions.Allows(notification)
+}
+
+// SetDoNotDisturb sets do not disturb to auto, on or off until the given unix time, after which it is back to auto.
+// An until of zero keeps the mode until it is changed. This is a non-standard message
+func (server *Server) SetDoNotDisturb(mode string, until int64) *dbus.Error {
+	fmt.Println("Received: SetDoNotDisturb", mode, until)
+	parsed, err := dnd.ParseMode(mode)
+	if err != nil {
+		return dbus.NewError(invalidArgsError, []interface{}{err.Error()})
+	}
+	if until < 0 {
+		return dbus.NewError(invalidArgsError, []interface{}{fmt.Sprintf("invalid time %d", until)})
+	}
+
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	server.dnd = dnd.State{Mode: parsed}
+	if until > 0 {
+		server.dnd.Until = time.Unix(until, 0)
+	}
+	server.checkDoNotDisturb()
+	server.publish()
+	return nil
+}
+
+// GetDoNotDisturb returns the mode in effect, the unix time it lasts until or zero, and whether the notifications
+// are held back right now. This is a non-standard message
+func (server *Server) GetDoNotDisturb() (string, int64, bool, *dbus.Error) {
+	fmt.Println("Received: GetDoNotDisturb")
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	now := time.Now()
+	mode := server.dnd.Effective(now)
+	var until int64
+	if mode != dnd.Auto && !server.dnd.Until.IsZero() {
+		until = server.dnd.Until.Unix()
+	}
+	return mode.String(), until, server.dnd.Active(server.config.QuietHours, now), nil
+}
+
+// ToggleMute turns do not disturb off if it is active, and on otherwise, going back to auto when the quiet hours
+// already give the wanted result. This is a non-standard message
+func (server *Server) ToggleMute() *dbus.Error {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	now := time.Now()
+	active := !server.dnd.Active(server.config.QuietHours, now)
+	if (dnd.State{}).Active(server.config.QuietHours, now) == active {
+		server.dnd = dnd.State{Mode: dnd.Auto}
+	} else if active {
+		server.dnd = dnd.State{Mode: dnd.On}
+	} else {
+		server.dnd = dnd.State{Mode: dnd.Off}
+	}
+	server.checkDoNotDisturb()
+	server.publish()
+	fmt.Println("Received: ToggleMute. Is muted? ", active)
+	return nil
+}
+
+// ListHeld returns up to limit notifications from the history that were held back by do not disturb or the rate
+// limits, newest first, skipping the first offset ones. A limit of zero returns all of them. This is a non-standard message
+func (server *Server) ListHeld(offset uint32, limit uint32) ([]history.Entry, *dbus.Error) {
+	fmt.Println("Received: ListHeld", offset, limit)
+	if server.history == nil {
+		return []history.Entry{}, nil
+	}
+	return server.history.Held(int(offset), int(limit)), nil
+}
@@ -0,0 +1,82 @@
+package notifyme
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ahirata/notifyme/internal/pkg/store"
+	"github.com/ahirata/notifyme/pkg/notifyme/schema"
+)
+
+// The methods below drive the expiration timers. They require the server lock, like the ones in lifecycle.go
+
+// expire closes the notifications whose timeout is over. The scheduler calls it from its timer
+func (server *Server) expire() {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	for _, id := range server.scheduler.Expire() {
+		server.close(id, schema.Expired)
+	}
+}
+
+// scheduleExpiration (re)starts the timer closing the notification once its timeout is over.
+// Critical notifications and the ones without timeout never expire. The timer starts paused while the popup is hovered
+// or the user is idle
+func (server *Server) scheduleExpiration(notification *schema.Notification) {
+	if notification.Urgency == schema.Critical || notification.ExpireTimeout <= 0 {
+		server.scheduler.Cancel(notification.ID)
+		return
+	}
+
+	paused := server.hovered[notification.ID] > 0 || server.idle
+	server.scheduler.Schedule(notification.ID, time.Duration(notification.ExpireTimeout)*time.Millisecond, paused)
+}
+
+// pointerEntered pauses the expiration while the pointer is over any copy of the popup
+func (server *Server) pointerEntered(id uint32) {
+	if entry := server.store.Get(id); entry == nil || entry.State != store.Shown {
+		return
+	}
+	server.hovered[id]++
+	server.scheduler.Pause(id)
+}
+
+// pointerLeft resumes the expiration with the time left, but no less than the configured minimum
+func (server *Server) pointerLeft(id uint32) {
+	if server.hovered[id] == 0 {
+		return
+	}
+	if server.hovered[id]--; server.hovered[id] > 0 {
+		return
+	}
+	delete(server.hovered, id)
+	if !server.idle {
+		server.scheduler.Resume(id, server.resumeTimeout())
+	}
+}
+
+// SetIdle freezes every expiration while the user is away, and resumes them with the time left,
+// but no less than the configured minimum, once the user is back
+func (server *Server) SetIdle(idle bool) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	if server.idle == idle {
+		return
+	}
+	server.idle = idle
+	fmt.Println("User idle:", idle)
+	for _, entry := range server.store.All() {
+		id := entry.Notification.ID
+		if idle {
+			server.scheduler.Pause(id)
+		} else if server.hovered[id] == 0 {
+			server.scheduler.Resume(id, server.resumeTimeout())
+		}
+	}
+}
+
+func (server *Server) resumeTimeout() time.Duration {
+	return time.Duration(server.config.ResumeTimeout) * time.Millisecond
+}
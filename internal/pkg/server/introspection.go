@@ -0,0 +1,128 @@
+package notifyme
+
+import (
+	"github.com/godbus/dbus"
+	"github.com/godbus/dbus/introspect"
+	"github.com/godbus/dbus/prop"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	introspectableInterface = "org.freedesktop.DBus.Introspectable"
+	deprecatedAnnotation    = "org.freedesktop.DBus.Deprecated"
+)
+
+// methodArgs names the arguments of the methods, inputs then outputs. Their types are read from the methods,
+// unless given here for the ones the Go type does not tell
+var methodArgs = map[string][]introspect.Arg{
+	"GetServerInformation":  {{Name: "name"}, {Name: "vendor"}, {Name: "version"}, {Name: "spec_version"}},
+	"GetCapabilities":       {{Name: "capabilities"}},
+	"Notify":                {{Name: "app_name"}, {Name: "replaces_id"}, {Name: "app_icon"}, {Name: "summary"}, {Name: "body"}, {Name: "actions", Type: "as"}, {Name: "hints"}, {Name: "expire_timeout"}, {Name: "id"}},
+	"CloseNotification":     {{Name: "id"}},
+	"CloseLastNotification": {},
+	"OpenLastNotification":  {},
+	"ToggleMute":            {},
+	"SetDoNotDisturb":       {{Name: "mode"}, {Name: "until"}},
+	"GetDoNotDisturb":       {{Name: "mode"}, {Name: "until"}, {Name: "active"}},
+	"ListHeld":              {{Name: "offset"}, {Name: "limit"}, {Name: "entries"}},
+	"ListHistory":           {{Name: "offset"}, {Name: "limit"}, {Name: "entries"}},
+	"SearchHistory":         {{Name: "query"}, {Name: "limit"}, {Name: "entries"}},
+	"GetHistoryEntry":       {{Name: "id"}, {Name: "entry"}},
+	"ListSuppressed":        {{Name: "suppressions"}},
+	"Kill":                  {},
+}
+
+var (
+	senderType = reflect.TypeOf(dbus.Sender(""))
+	errorType  = reflect.TypeOf(&dbus.Error{})
+)
+
+// introspection describes the interfaces of the notifications object
+func introspection(methodTable map[string]interface{}, vendorTable map[string]interface{}, props *prop.Properties) *introspect.Node {
+	standard := introspect.Interface{
+		Name:    serviceInterface,
+		Methods: append(methods(methodTable, false), methods(vendorTable, true)...),
+		Signals: []introspect.Signal{
+			{Name: "NotificationClosed", Args: []introspect.Arg{{Name: "id", Type: "u"}, {Name: "reason", Type: "u"}}},
+			{Name: "ActionInvoked", Args: []introspect.Arg{{Name: "id", Type: "u"}, {Name: "action_key", Type: "s"}}},
+		},
+	}
+	properties := props.Introspection(vendorInterface)
+	sort.Slice(properties, func(i, j int) bool { return properties[i].Name < properties[j].Name })
+	vendor := introspect.Interface{
+		Name:       vendorInterface,
+		Methods:    methods(vendorTable, false),
+		Properties: properties,
+	}
+	return &introspect.Node{
+		Name:       objectPath,
+		Interfaces: []introspect.Interface{introspect.IntrospectData, prop.IntrospectData, standard, vendor},
+	}
+}
+
+// methods describes the methods of the table sorted by name, annotated as deprecated if asked
+func methods(methodTable map[string]interface{}, deprecated bool) []introspect.Method {
+	var names []string
+	for name := range methodTable {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var described []introspect.Method
+	for _, name := range names {
+		method := introspect.Method{Name: name}
+		kind := reflect.TypeOf(methodTable[name])
+		var types []reflect.Type
+		var directions []string
+		for i := 0; i < kind.NumIn(); i++ {
+			if kind.In(i) != senderType {
+				types = append(types, kind.In(i))
+				directions = append(directions, "in")
+			}
+		}
+		for i := 0; i < kind.NumOut(); i++ {
+			if kind.Out(i) != errorType {
+				types = append(types, kind.Out(i))
+				directions = append(directions, "out")
+			}
+		}
+		for i, argType := range types {
+			arg := introspect.Arg{Type: dbus.SignatureOfType(argType).String(), Direction: directions[i]}
+			if i < len(methodArgs[name]) {
+				arg.Name = methodArgs[name][i].Name
+				if methodArgs[name][i].Type != "" {
+					arg.Type = methodArgs[name][i].Type
+				}
+			}
+			method.Args = append(method.Args, arg)
+		}
+		if deprecated {
+			method.Annotations = []introspect.Annotation{{Name: deprecatedAnnotation, Value: "true"}}
+		}
+		described = append(described, method)
+	}
+	return described
+}
+
+// exportIntrospection exports the introspection data of the object, and of each path leading to it
+// so that it can be found by browsing the bus from the root
+func exportIntrospection(conn *dbus.Conn, node *introspect.Node) error {
+	if err := conn.Export(introspect.NewIntrospectable(node), dbus.ObjectPath(node.Name), introspectableInterface); err != nil {
+		return err
+	}
+	for child := node.Name; child != "/"; child = path.Dir(child) {
+		parent := path.Dir(child)
+		leading := &introspect.Node{
+			Name:       parent,
+			Interfaces: []introspect.Interface{introspect.IntrospectData},
+			Children:   []introspect.Node{{Name: strings.TrimPrefix(child[len(parent):], "/")}},
+		}
+		if err := conn.Export(introspect.NewIntrospectable(leading), dbus.ObjectPath(parent), introspectableInterface); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package notifyme
+
+import (
+	"fmt"
+	"github.com/ahirata/notifyme/internal/pkg/config"
+	"github.com/ahirata/notifyme/internal/pkg/render"
+	"github.com/ahirata/notifyme/internal/pkg/rules"
+	"github.com/ahirata/notifyme/internal/pkg/store"
+	"github.com/ahirata/notifyme/pkg/notifyme/schema"
+)
+
+// The methods below drive the lifecycle of the notifications (pending, shown, closing and closed).
+// They must be called with the server lock held, and close and closeMerged are the only places where NotificationClosed
+// is emitted, never for the popups of the server itself
+
+// show displays a pending notification and starts its expiration
+func (server *Server) show(entry *store.Entry) {
+	if !entry.Transition(store.Shown) {
+		return
+	}
+	server.renderer.Show(popup(entry))
+	server.scheduleExpiration(entry.Notification)
+}
+
+// promote shows the pending notifications while there is room for them, the most urgent first
+func (server *Server) promote() {
+	for {
+		next := server.store.NextPending()
+		if next == nil || !server.hasRoom(next) {
+			break
+		}
+		server.show(next)
+	}
+
+	if queued := server.store.Count(store.Pending); queued != server.queued {
+		server.queued = queued
+		server.renderer.SetQueued(queued)
+	}
+	server.publish()
+}
+
+// hasRoom tells whether the entry can be shown without going over the visible limit. A stack takes a single place,
+// so joining one that is shown is always possible
+func (server *Server) hasRoom(entry *store.Entry) bool {
+	if server.config.MaxVisible <= 0 {
+		return true
+	}
+	visible := 0
+	groups := map[string]bool{}
+	for _, shown := range server.store.All() {
+		if shown.State != store.Shown {
+			continue
+		}
+		if shown.Group == "" {
+			visible++
+		} else if !groups[shown.Group] {
+			groups[shown.Group] = true
+			visible++
+		}
+	}
+	return groups[entry.Group] || visible < server.config.MaxVisible
+}
+
+// group returns the stack the notification belongs to, as configured
+func (server *Server) group(notification *schema.Notification) string {
+	if server.config.GroupBy != config.GroupApp {
+		return ""
+	}
+	if desktopEntry, _, _ := notification.Hints.DesktopEntry(); desktopEntry != "" {
+		return desktopEntry
+	}
+	return notification.AppName
+}
+
+// replace updates an open notification with the same id, returning false if there is none
+func (server *Server) replace(notification *schema.Notification, result rules.Result) bool {
+	entry := server.store.Get(notification.ID)
+	if entry == nil || !entry.IsOpen() {
+		return false
+	}
+
+	entry.Notification = notification
+	entry.Classes = result.Classes
+	entry.Mirror = result.Mirror
+	entry.Group = server.group(notification)
+	if entry.State == store.Shown {
+		server.renderer.Update(popup(entry))
+		server.scheduleExpiration(notification)
+	}
+	return true
+}
+
+// pushInternal adds a popup of the server itself, whose actions and closing are not reported to the clients
+func (server *Server) pushInternal(notification *schema.Notification) *store.Entry {
+	entry := server.store.Push(notification)
+	entry.Internal = true
+	return entry
+}
+
+func popup(entry *store.Entry) render.Popup {
+	return render.Popup{
+		Notification: entry.Notification,
+		Classes:      entry.Classes,
+		Mirror:       entry.Mirror,
+		Group:        entry.Group,
+		Repeats:      entry.Repeats(),
+	}
+}
+
+// invokeAction emits ActionInvoked for the notification and the ones merged into it, and closes them unless
+// the notification is resident. The popups of the server itself handle their actions on their own
+func (server *Server) invokeAction(id uint32, actionKey string) {
+	entry := server.store.Get(id)
+	if entry == nil || entry.State != store.Shown {
+		return
+	}
+	if entry.Internal {
+		if !server.missedAction(id, actionKey) {
+			server.close(id, schema.Dismissed)
+		}
+		return
+	}
+
+	server.Signals <- schema.ActionInvoked{ID: id, ActionKey: actionKey}
+	for _, merged := range entry.Merged {
+		server.Signals <- schema.ActionInvoked{ID: merged, ActionKey: actionKey}
+	}
+	if resident, _, _ := entry.Notification.Hints.Resident(); !resident {
+		server.close(id, schema.Dismissed)
+	}
+}
+
+// close takes down an open notification and emits NotificationClosed with the given reason, for the notifications
+// merged into it as well, unless it is a popup of the server itself. It returns false if the notification is unknown
+// or was already closed
+func (server *Server) close(id uint32, reason uint32) bool {
+	entry := server.store.Get(id)
+	if entry == nil {
+		return false
+	}
+	shown := entry.State == store.Shown
+	if !entry.Transition(store.Closing) {
+		return false
+	}
+
+	if shown {
+		server.renderer.Close(id)
+	}
+	server.scheduler.Cancel(id)
+	delete(server.hovered, id)
+	delete(server.replays, id)
+	server.store.Remove(id)
+	entry.Transition(store.Closed)
+
+	fmt.Printf("Closed notification %d with reason %d\n", id, reason)
+	if !entry.Internal {
+		server.Signals <- schema.NotificationClosed{ID: id, Reason: reason}
+		for _, merged := range entry.Merged {
+			server.Signals <- schema.NotificationClosed{ID: merged, Reason: reason}
+		}
+	}
+	server.promote()
+	return true
+}
+
+// closeMerged closes a notification that was merged into another one, which stays with one repeat less.
+// It returns false if the notification was not merged
+func (server *Server) closeMerged(id uint32, reason uint32) bool {
+	if !server.unmerge(id) {
+		return false
+	}
+
+	fmt.Printf("Closed merged notification %d with reason %d\n", id, reason)
+	server.Signals <- schema.NotificationClosed{ID: id, Reason: reason}
+	return true
+}
@@ -0,0 +1,193 @@
+package notifyme
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ahirata/notifyme/internal/pkg/config"
+	"github.com/ahirata/notifyme/internal/pkg/rules"
+	"github.com/ahirata/notifyme/pkg/notifyme/schema"
+)
+
+// Actions of the popups listing the notifications missed while do not disturb was active
+const (
+	expandAction = "expand"
+	replayAction = "replay"
+)
+
+// maxMissed bounds the notifications kept for the summary, the oldest being dropped
+const maxMissed = 1000
+
+// missedPage is how many missed notifications are listed at once
+const missedPage = 10
+
+// missedGroup stacks the popups listing the missed notifications
+const missedGroup = "notifyme-missed"
+
+// The methods below require the server lock, like the ones in lifecycle.go
+
+// hold keeps a notification held back by do not disturb for the summary shown once it is over
+func (server *Server) hold(notification *schema.Notification) {
+	server.missed = append(server.missed, notification)
+	if len(server.missed) > maxMissed {
+		server.missed = server.missed[len(server.missed)-maxMissed:]
+	}
+}
+
+// checkDoNotDisturb summarizes the missed notifications when do not disturb is over
+func (server *Server) checkDoNotDisturb() {
+	active := server.dnd.Active(server.config.QuietHours, time.Now())
+	if server.dndActive && !active && len(server.missed) > 0 {
+		server.summarizeMissed()
+	}
+	server.dndActive = active
+	server.publish()
+}
+
+// WatchDoNotDisturb checks every interval whether the quiet hours or a mode set until some time are over,
+// to summarize the missed notifications. It returns a function that stops watching
+func (server *Server) WatchDoNotDisturb(interval time.Duration) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				server.lock.Lock()
+				server.checkDoNotDisturb()
+				server.lock.Unlock()
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// summarizeMissed shows how many notifications were missed, and from which apps, in a single popup that can be
+// expanded to list them. The summary still open from a previous period is updated to cover both
+func (server *Server) summarizeMissed() {
+	if entry := server.store.Get(server.summaryID); entry == nil || !entry.IsOpen() {
+		server.summaryID = server.internalID()
+		server.summarized = nil
+	}
+	server.summarized = append(server.summarized, server.missed...)
+	server.missed = nil
+	server.showSummary(fmt.Sprintf("%d notifications while muted", len(server.summarized)), "Show all")
+}
+
+// showSummary shows or updates the summary popup, counting the summarized notifications
+func (server *Server) showSummary(summary string, label string) {
+	notification := &schema.Notification{
+		ID:            server.summaryID,
+		AppName:       server.info.Name,
+		Summary:       summary,
+		Body:          missedApps(server.summarized),
+		Actions:       []interface{}{expandAction, label},
+		Hints:         schema.Hints{},
+		ExpireTimeout: 0,
+		Urgency:       schema.Normal,
+	}
+	if !server.replace(notification, rules.Result{}) {
+		server.pushInternal(notification)
+		server.promote()
+	}
+}
+
+// missedApps tells how many notifications came from the apps that sent the most
+func missedApps(notifications []*schema.Notification) string {
+	counts := map[string]int{}
+	for _, notification := range notifications {
+		counts[notification.AppName]++
+	}
+	apps := make([]string, 0, len(counts))
+	for app := range counts {
+		apps = append(apps, app)
+	}
+	sort.Slice(apps, func(i, j int) bool {
+		if counts[apps[i]] != counts[apps[j]] {
+			return counts[apps[i]] > counts[apps[j]]
+		}
+		return apps[i] < apps[j]
+	})
+
+	var parts []string
+	for i, app := range apps {
+		if i == 3 {
+			return strings.Join(parts, ", ") + "…"
+		}
+		parts = append(parts, fmt.Sprintf("%d from %s", counts[app], app))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// missedAction handles the actions of the summary and of the popups listing the missed notifications.
+// It returns false for any other notification
+func (server *Server) missedAction(id uint32, actionKey string) bool {
+	if id == server.summaryID && server.summaryID != 0 {
+		summarized := server.summarized
+		server.summarized = nil
+		server.close(id, schema.Dismissed)
+		if actionKey == expandAction {
+			server.listMissed(summarized)
+		}
+		return true
+	}
+	original, found := server.replays[id]
+	if !found {
+		return false
+	}
+	server.close(id, schema.Dismissed)
+	if actionKey == replayAction {
+		server.replay(original)
+	}
+	return true
+}
+
+// listMissed shows a popup for a page of the missed notifications, stacked together, from which each one can be
+// replayed. The rest are left in a summary that lists the next page when expanded
+func (server *Server) listMissed(notifications []*schema.Notification) {
+	page := notifications
+	if len(page) > missedPage {
+		page = notifications[:missedPage]
+	}
+	for _, original := range page {
+		notification := &schema.Notification{
+			ID:            server.internalID(),
+			AppName:       original.AppName,
+			AppIcon:       original.AppIcon,
+			Summary:       original.Summary,
+			Body:          original.Body,
+			Actions:       []interface{}{replayAction, "Replay"},
+			Hints:         schema.Hints{},
+			ExpireTimeout: server.notificationTimeout(-1),
+			Urgency:       schema.Normal,
+		}
+		server.replays[notification.ID] = original
+		entry := server.pushInternal(notification)
+		if server.config.GroupBy != config.GroupNone {
+			entry.Group = missedGroup
+		}
+	}
+	server.promote()
+
+	if rest := notifications[len(page):]; len(rest) > 0 {
+		server.summaryID = server.internalID()
+		server.summarized = rest
+		server.showSummary(fmt.Sprintf("%d more notifications while muted", len(rest)), "Show more")
+	}
+}
+
+// replay shows a missed notification again as it was sent, under an id of its own since the client was already
+// told it was closed. Its actions are left out, as they would not reach the client
+func (server *Server) replay(original *schema.Notification) {
+	notification := *original
+	notification.ID = server.internalID()
+	notification.Actions = nil
+	entry := server.pushInternal(&notification)
+	entry.Group = server.group(&notification)
+	server.promote()
+}
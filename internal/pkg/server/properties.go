@@ -0,0 +1,66 @@
+package notifyme
+
+import (
+	"github.com/ahirata/notifyme/internal/pkg/dnd"
+	"github.com/ahirata/notifyme/internal/pkg/store"
+	"time"
+)
+
+// Status is the state of the server exposed as properties of the vendor interface, so that status bars can follow it
+// without polling
+type Status struct {
+	// Muted tells whether do not disturb holds the notifications back right now
+	Muted bool
+	// DoNotDisturb is the mode in effect, auto, on or off, and DoNotDisturbUntil the unix time it lasts until, or zero
+	DoNotDisturb      string
+	DoNotDisturbUntil int64
+	// Visible and Queued count the notifications shown and waiting for room
+	Visible uint32
+	Queued  uint32
+	// HistoryCount is the number of notifications in the history
+	HistoryCount uint32
+}
+
+// properties returns the status by property name
+func (status Status) properties() map[string]interface{} {
+	return map[string]interface{}{
+		"Muted":             status.Muted,
+		"DoNotDisturb":      status.DoNotDisturb,
+		"DoNotDisturbUntil": status.DoNotDisturbUntil,
+		"Visible":           status.Visible,
+		"Queued":            status.Queued,
+		"HistoryCount":      status.HistoryCount,
+	}
+}
+
+// The methods below require the server lock, like the ones in lifecycle.go
+
+// status returns the current state of the server
+func (server *Server) status() Status {
+	now := time.Now()
+	mode := server.dnd.Effective(now)
+	status := Status{
+		Muted:        server.dnd.Active(server.config.QuietHours, now),
+		DoNotDisturb: mode.String(),
+		Visible:      uint32(server.store.Count(store.Shown)),
+		Queued:       uint32(server.store.Count(store.Pending)),
+	}
+	if mode != dnd.Auto && !server.dnd.Until.IsZero() {
+		status.DoNotDisturbUntil = server.dnd.Until.Unix()
+	}
+	if server.history != nil {
+		status.HistoryCount = uint32(server.history.Len())
+	}
+	return status
+}
+
+// publish reports the status to the watcher set by Serve, if it changed since the last time
+func (server *Server) publish() {
+	if server.watch == nil {
+		return
+	}
+	if status := server.status(); status != server.published {
+		server.published = status
+		server.watch(status)
+	}
+}
@@ -0,0 +1,128 @@
+package notifyme
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ahirata/notifyme/internal/pkg/config"
+	"github.com/ahirata/notifyme/internal/pkg/ratelimit"
+	"github.com/ahirata/notifyme/internal/pkg/rules"
+	"github.com/ahirata/notifyme/pkg/notifyme/schema"
+	"github.com/godbus/dbus"
+)
+
+// Kinds of suppression
+const (
+	appSuppression    = "app"
+	senderSuppression = "sender"
+)
+
+// suppression counts the notifications over the limits of an app or a sender, along with the popup summarizing them
+type suppression struct {
+	schema.Suppression
+	summaryID uint32
+	pending   uint32
+}
+
+// The methods below require the server lock, like the ones in lifecycle.go
+
+// configureLimits sizes the buckets after the configuration. The limits left unchanged keep their buckets, so that
+// reloading the configuration gives no burst back to the clients being limited
+func (server *Server) configureLimits(configuration *config.Config) {
+	if server.appLimiter == nil || !server.appLimiter.Sized(configuration.AppRate, configuration.AppBurst) {
+		server.appLimiter = ratelimit.LimiterNew(configuration.AppRate, configuration.AppBurst)
+	}
+	if server.senderLimiter == nil || !server.senderLimiter.Sized(configuration.SenderRate, configuration.SenderBurst) {
+		server.senderLimiter = ratelimit.LimiterNew(configuration.SenderRate, configuration.SenderBurst)
+	}
+}
+
+// rateLimit takes a token from the buckets of the app and the sender of the notification, returning the suppression
+// to count it in if either is empty. Senders are told apart by their process, since scripts connect anew on every call
+func (server *Server) rateLimit(notification *schema.Notification, sender rules.Sender) *suppression {
+	if !server.appLimiter.Allow(notification.AppName) {
+		return server.suppression(appSuppression, notification.AppName)
+	}
+	name := sender.ProcessName()
+	if name == "" {
+		name = sender.BusName()
+	}
+	if name != "" && !server.senderLimiter.Allow(name) {
+		return server.suppression(senderSuppression, name)
+	}
+	return nil
+}
+
+func (server *Server) suppression(kind string, name string) *suppression {
+	key := kind + " " + name
+	if _, found := server.suppressed[key]; !found {
+		server.suppressed[key] = &suppression{Suppression: schema.Suppression{Kind: kind, Name: name}}
+	}
+	return server.suppressed[key]
+}
+
+// suppress handles a notification over the limits as configured, closing it right away. While do not disturb holds
+// it back, it is counted in the missed summary rather than in a popup of its own
+func (server *Server) suppress(notification *schema.Notification, suppression *suppression, result rules.Result) {
+	suppression.Count++
+	fmt.Printf("Suppressed notification %d over the limits of %s %s\n", notification.ID, suppression.Kind, suppression.Name)
+
+	if server.config.Excess == config.ExcessHistory && !result.SkipHistory {
+		server.record(notification, true)
+	}
+	server.store.Push(notification)
+	server.close(notification.ID, schema.Undefined)
+
+	if server.config.Excess != config.ExcessSummarize {
+		return
+	}
+	if !server.holds(notification) {
+		server.summarize(suppression)
+	} else if !result.Suppress {
+		server.hold(notification)
+	}
+}
+
+// summarize shows how many notifications were suppressed, updating the summary popup while it is open
+func (server *Server) summarize(suppression *suppression) {
+	if entry := server.store.Get(suppression.summaryID); entry != nil && entry.IsOpen() {
+		suppression.pending++
+	} else {
+		suppression.summaryID = server.internalID()
+		suppression.pending = 1
+	}
+
+	notification := &schema.Notification{
+		ID:            suppression.summaryID,
+		AppName:       server.info.Name,
+		Summary:       fmt.Sprintf("%d notifications suppressed from %s", suppression.pending, suppression.Name),
+		Hints:         schema.Hints{},
+		ExpireTimeout: server.notificationTimeout(-1),
+		Urgency:       schema.Normal,
+	}
+	if !server.replace(notification, rules.Result{}) {
+		entry := server.pushInternal(notification)
+		entry.Group = server.group(notification)
+		server.promote()
+	}
+}
+
+// ListSuppressed returns how many notifications went over the rate limits of each app and sender since the server
+// started. This is a non-standard message
+func (server *Server) ListSuppressed() ([]schema.Suppression, *dbus.Error) {
+	fmt.Println("Received: ListSuppressed")
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	suppressions := []schema.Suppression{}
+	for _, suppression := range server.suppressed {
+		suppressions = append(suppressions, suppression.Suppression)
+	}
+	sort.Slice(suppressions, func(i, j int) bool {
+		if suppressions[i].Kind != suppressions[j].Kind {
+			return suppressions[i].Kind < suppressions[j].Kind
+		}
+		return suppressions[i].Name < suppressions[j].Name
+	})
+	return suppressions, nil
+}
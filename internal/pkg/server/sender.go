@@ -0,0 +1,78 @@
+package notifyme
+
+import (
+	"fmt"
+	"github.com/godbus/dbus"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// maxProcesses bounds the process names remembered, since scripts connect anew on every call
+const maxProcesses = 256
+
+// busSender identifies a client by its unique bus name and its process
+type busSender struct {
+	name        string
+	processName string
+}
+
+// processCache remembers the process of each client by its unique bus name, which the bus never hands out twice
+type processCache struct {
+	lock  sync.Mutex
+	names map[string]string
+}
+
+// sender looks up the process of the client. It makes a bus call the first time a client is seen, so it must be
+// called without the server lock
+func (server *Server) sender(sender dbus.Sender) *busSender {
+	name := string(sender)
+	return &busSender{name: name, processName: server.processes.lookup(server.conn, name)}
+}
+
+// BusName returns the unique name of the client
+func (sender *busSender) BusName() string {
+	return sender.name
+}
+
+// ProcessName returns the name of the executable of the client, as found in /proc
+func (sender *busSender) ProcessName() string {
+	return sender.processName
+}
+
+func (cache *processCache) lookup(conn *dbus.Conn, name string) string {
+	if conn == nil || name == "" {
+		return ""
+	}
+
+	cache.lock.Lock()
+	processName, found := cache.names[name]
+	cache.lock.Unlock()
+	if found {
+		return processName
+	}
+
+	processName = lookupProcessName(conn, name)
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+	if cache.names == nil || len(cache.names) >= maxProcesses {
+		cache.names = map[string]string{}
+	}
+	cache.names[name] = processName
+	return processName
+}
+
+func lookupProcessName(conn *dbus.Conn, name string) string {
+	var pid uint32
+	call := conn.BusObject().Call("org.freedesktop.DBus.GetConnectionUnixProcessID", 0, name)
+	if err := call.Store(&pid); err != nil {
+		fmt.Println("Unable to find the process of", name, err)
+		return ""
+	}
+
+	comm, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(comm))
+}
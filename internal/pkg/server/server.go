@@ -2,47 +2,80 @@ package notifyme
 
 import (
 	"fmt"
+	"github.com/ahirata/notifyme/internal/pkg/config"
+	"github.com/ahirata/notifyme/internal/pkg/dnd"
+	"github.com/ahirata/notifyme/internal/pkg/history"
+	"github.com/ahirata/notifyme/internal/pkg/ratelimit"
+	"github.com/ahirata/notifyme/internal/pkg/render"
+	"github.com/ahirata/notifyme/internal/pkg/rules"
+	"github.com/ahirata/notifyme/internal/pkg/scheduler"
 	"github.com/ahirata/notifyme/internal/pkg/store"
-	"github.com/ahirata/notifyme/internal/pkg/ui"
 	"github.com/ahirata/notifyme/pkg/notifyme/schema"
 	"github.com/godbus/dbus"
-	"github.com/gotk3/gotk3/glib"
-	"github.com/gotk3/gotk3/gtk"
+	"sync"
 	"sync/atomic"
-	"time"
 )
 
 // Server ...
 type Server struct {
-	conn                     *dbus.Conn
-	capabilities             []string
-	counter                  uint32
-	defaultTimeout           int32
-	mute                     bool
-	info                     schema.ServerInformation
-	store                    store.WidgetStore
-	NotificationClosedSignal chan schema.NotificationClosed
-	ActionInvokedSignal      chan schema.ActionInvoked
-}
-
-// ServerNew ...
-func ServerNew() Server {
+	lock    sync.Mutex
+	conn    *dbus.Conn
+	config  *config.Config
+	counter uint32
+	// internalCounter numbers the popups of the server itself, down from the highest id
+	internalCounter uint32
+	// dnd is the do not disturb mode set by the user, which the quiet hours of the configuration complete
+	dnd       dnd.State
+	dndActive bool
+	// missed holds the notifications held back by do not disturb, and summarized the ones counted in the summary
+	// popup, while replays maps the popups listing them to the notifications they replay
+	missed     []*schema.Notification
+	summarized []*schema.Notification
+	summaryID  uint32
+	replays    map[uint32]*schema.Notification
+	queued     int
+	// scheduler holds the expiration timers of the shown notifications, and hovered counts the pointers over their popups
+	scheduler *scheduler.Scheduler
+	hovered   map[uint32]int
+	idle      bool
+	// appLimiter and senderLimiter hold the rate limits, and suppressed counts the notifications over them
+	appLimiter    *ratelimit.Limiter
+	senderLimiter *ratelimit.Limiter
+	suppressed    map[string]*suppression
+	processes     processCache
+	info          schema.ServerInformation
+	renderer      render.Renderer
+	history       *history.History
+	store         store.NotificationStore
+	Signals       chan interface{}
+
+	// watch is told about the changes of the status, last published
+	watch     func(Status)
+	published Status
+}
+
+// ServerNew creates a server displaying the notifications on renderer. The history may be nil to disable it
+func ServerNew(renderer render.Renderer, history *history.History) *Server {
 	server := Server{
-		capabilities:   []string{"body", "actions", "body-hyperlinks", "body-markup"},
-		counter:        0,
-		defaultTimeout: 10000,
-		mute:           false,
+		config:  config.Default(),
+		counter: 0,
 		info: schema.ServerInformation{
 			Name:        "notifyme",
 			Vendor:      "ahirata",
 			Version:     "0.0.1",
 			SpecVersion: "1.2",
 		},
-		NotificationClosedSignal: make(chan schema.NotificationClosed, 10),
-		ActionInvokedSignal:      make(chan schema.ActionInvoked, 10),
-		store:                    store.WidgetStore{},
+		Signals:    make(chan interface{}, 100),
+		renderer:   renderer,
+		history:    history,
+		store:      store.NotificationStore{},
+		hovered:    map[uint32]int{},
+		suppressed: map[string]*suppression{},
+		replays:    map[uint32]*schema.Notification{},
 	}
-	return server
+	server.scheduler = scheduler.SchedulerNew(server.expire)
+	server.configureLimits(server.config)
+	return &server
 }
 
 // GetServerInformation returns the information on the server. Specifically, the server name, vendor, and version number
@@ -54,161 +87,317 @@ func (server *Server) GetServerInformation() (string, string, string, string, *d
 // GetCapabilities returns an array of strings. Each string describes an optional capability implemented by the server
 func (server *Server) GetCapabilities() ([]string, *dbus.Error) {
 	fmt.Println("Received: GetCapabilities")
-	return server.capabilities, nil
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	return server.config.Capabilities, nil
+}
+
+// Configure applies a new configuration, keeping the notifications already on screen
+func (server *Server) Configure(configuration *config.Config) {
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	server.config = configuration
+	server.renderer.Configure(configuration)
+	server.configureLimits(configuration)
+	server.checkDoNotDisturb()
+	server.promote()
+	if server.history != nil {
+		if err := server.history.SetLimits(configuration.HistoryMaxEntries, configuration.HistoryMaxAge); err != nil {
+			fmt.Println("Unable to apply the history limits:", err)
+		}
+	}
+	server.publish()
 }
 
 // Notify sends a notification to this notification server
-func (server *Server) Notify(appName string, replacesID uint32, appIcon string, summary string, body string, actions []interface{}, hints map[string]dbus.Variant, expireTimeout int32) (uint32, *dbus.Error) {
-	fmt.Printf("Received: Notify(%s, %d, %s, %s, %s, %v, %d)\n", appName, replacesID, appIcon, summary, body, actions, expireTimeout)
+func (server *Server) Notify(appName string, replacesID uint32, appIcon string, summary string, body string, actions []interface{}, hints map[string]dbus.Variant, expireTimeout int32, sender dbus.Sender) (uint32, *dbus.Error) {
+	fmt.Printf("Received: Notify(%s, %d, %s, %s, %s, %v, %d) from %s\n", appName, replacesID, appIcon, summary, body, actions, expireTimeout, sender)
 
 	notification := schema.Notification{
-		ID:            server.notificationID(replacesID),
-		AppName:       appName,
-		ReplacesID:    replacesID,
-		AppIcon:       appIcon,
-		Summary:       summary,
-		Body:          body,
-		Actions:       actions,
-		Hints:         hints,
-		ExpireTimeout: server.notificationTimeout(expireTimeout),
-	}
-
-	if server.mute {
-		return notification.ID, nil
+		AppName:    appName,
+		ReplacesID: replacesID,
+		AppIcon:    appIcon,
+		Summary:    summary,
+		Body:       body,
+		Actions:    actions,
+		Hints:      hints,
+	}
+	if err := notification.Validate(); err != nil {
+		fmt.Println("Rejecting notification:", err)
+		return 0, dbus.NewError(invalidArgsError, []interface{}{err.Error()})
 	}
+	notification.Urgency = notification.UrgencyHint()
+	from := server.sender(sender)
 
-	glib.IdleAdd(func() {
-		widget := server.store.Get(notification.ID)
-		if widget != nil {
-			widget.ReplaceNotification(&notification)
-			return
-		}
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	// only valid notifications take an id
+	notification.ID = server.notificationID(replacesID)
+	notification.ExpireTimeout = server.notificationTimeout(expireTimeout)
+	server.unmerge(replacesID)
+	result := rules.Apply(server.config.Rules, &notification, from)
+	if len(result.Matched) > 0 {
+		fmt.Println("Rules matched:", result.Matched)
+	}
+	result.Mirror = result.Mirror || server.config.Mirrors(notification.Urgency)
 
-		widget, err := ui.NotificationWidgetNew(&notification, server.store.MinY(), server.ActionInvokedSignal)
-		if err != nil {
-			fmt.Println("Error building widget", err)
+	duplicate := server.duplicate(&notification)
+	if entry := server.store.Get(notification.ID); entry == nil || !entry.IsOpen() {
+		if suppression := server.rateLimit(&notification, from); suppression != nil {
+			server.suppress(&notification, suppression, result)
+			return notification.ID, nil
 		}
-		server.store.Push(widget)
-		widget.Show()
-	})
+	}
+
+	held := server.holds(&notification)
+	if !result.SkipHistory {
+		server.record(&notification, held)
+	}
+	if held && !result.Suppress {
+		server.hold(&notification)
+	}
+	if held || result.Suppress {
+		server.store.Push(&notification)
+		server.close(notification.ID, schema.Undefined)
+		return notification.ID, nil
+	}
 
-	if notification.ExpireTimeout > 0 {
-		go server.scheduleExpiration(&notification)
+	if duplicate != nil {
+		server.merge(duplicate, &notification)
+	} else if !server.replace(&notification, result) {
+		entry := server.store.Push(&notification)
+		entry.Classes = result.Classes
+		entry.Mirror = result.Mirror
+		entry.Group = server.group(&notification)
+		server.promote()
 	}
 
 	return notification.ID, nil
 }
 
+// notificationID returns the id of a client notification, new unless it replaces one. The popups of the server
+// itself are never replaced by the clients. It requires the server lock
 func (server *Server) notificationID(replacesID uint32) uint32 {
-	if replacesID > 0 {
+	if replacesID > 0 && !server.internal(replacesID) {
 		return replacesID
 	}
 	return atomic.AddUint32(&server.counter, 1)
 }
 
+// internalID returns a new id for a popup of the server itself, apart from the ones of the clients
+func (server *Server) internalID() uint32 {
+	return atomic.AddUint32(&server.internalCounter, ^uint32(0))
+}
+
+// internal tells whether the id belongs to an open popup of the server itself. It requires the server lock
+func (server *Server) internal(id uint32) bool {
+	entry := server.store.Get(id)
+	return entry != nil && entry.Internal
+}
+
 func (server *Server) notificationTimeout(requestedTimeout int32) int32 {
 	if requestedTimeout < 0 {
-		return server.defaultTimeout
+		return server.config.Timeout
 	}
 	return requestedTimeout
 }
 
-func (server *Server) scheduleExpiration(notification *schema.Notification) {
-	select {
-	case <-time.After(time.Duration(notification.ExpireTimeout) * time.Millisecond):
-		glib.IdleAdd(func() {
-			if widget := server.store.Get(notification.ID); widget == nil || widget.Notification != notification {
-				return
-			}
-			if removed := server.store.Remove(notification.ID); removed != nil {
-				removed.Close()
-			}
-
-			server.NotificationClosedSignal <- schema.NotificationClosed{ID: notification.ID, Reason: schema.Expired}
-		})
+// record adds the notification to the history, unless it asked to be transient. Muted tells it was not shown
+func (server *Server) record(notification *schema.Notification, muted bool) {
+	if transient, _, _ := notification.Hints.Transient(); server.history == nil || transient {
+		return
+	}
+	if _, err := server.history.Record(notification, muted); err != nil {
+		fmt.Println("Unable to record notification:", err)
 	}
+	server.publish()
 }
 
-// CloseNotification causes a notification to be forcefully closed and removed from the user's view
+// CloseNotification causes a notification to be forcefully closed and removed from the user's view.
+// Unknown or already closed notifications, and the popups of the server itself, are ignored
 func (server *Server) CloseNotification(id uint32) *dbus.Error {
 	fmt.Println("Received: CloseNotification: ", id)
-	glib.IdleAdd(func() {
-		if removed := server.store.Remove(id); removed != nil {
-			removed.Close()
-		}
-		server.NotificationClosedSignal <- schema.NotificationClosed{ID: id, Reason: schema.Closed}
-	})
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	if server.internal(id) {
+		return nil
+	}
+	if !server.close(id, schema.Closed) {
+		server.closeMerged(id, schema.Closed)
+	}
 	return nil
 }
 
 // CloseLastNotification closes the most recent notification. This is a non-standard message
 func (server *Server) CloseLastNotification() *dbus.Error {
 	fmt.Println("Received: CloseLastNotification")
-	glib.IdleAdd(func() {
-		if server.store.IsEmpty() {
-			return
-		}
+	server.lock.Lock()
+	defer server.lock.Unlock()
 
-		widget := server.store.Pop()
-		widget.Close()
-		server.NotificationClosedSignal <- schema.NotificationClosed{ID: widget.Notification.ID, Reason: schema.Dismissed}
-	})
+	if entry := server.store.Last(); entry != nil {
+		server.close(entry.Notification.ID, schema.Dismissed)
+	}
 	return nil
 }
 
 // OpenLastNotification opens the application that sent the most recent notification. This is a non-standard message
 func (server *Server) OpenLastNotification() *dbus.Error {
 	fmt.Println("Received: OpenLastNotification")
-	glib.IdleAdd(func() {
-		if server.store.IsEmpty() {
-			return
-		}
+	server.lock.Lock()
+	defer server.lock.Unlock()
 
-		widget := server.store.Pop()
-		widget.CloseAction("default")
-		server.NotificationClosedSignal <- schema.NotificationClosed{ID: widget.Notification.ID, Reason: schema.Dismissed}
-	})
+	if entry := server.store.Last(); entry != nil {
+		server.invokeAction(entry.Notification.ID, "default")
+	}
 	return nil
 }
 
-// ToggleMute controls if future messages will be displayed to the user or not. This is a non-standard message
-func (server *Server) ToggleMute() *dbus.Error {
-	server.mute = !server.mute
-	fmt.Println("Received: ToggleMute. Is muted? ", server.mute)
-	return nil
+// ListHistory returns up to limit notifications from the history, newest first, skipping the first offset ones.
+// A limit of zero returns all of them. This is a non-standard message
+func (server *Server) ListHistory(offset uint32, limit uint32) ([]history.Entry, *dbus.Error) {
+	fmt.Println("Received: ListHistory", offset, limit)
+	if server.history == nil {
+		return []history.Entry{}, nil
+	}
+	return server.history.List(int(offset), int(limit)), nil
+}
+
+// SearchHistory returns up to limit notifications from the history, newest first, whose app name, summary or body
+// contain the query. This is a non-standard message
+func (server *Server) SearchHistory(query string, limit uint32) ([]history.Entry, *dbus.Error) {
+	fmt.Println("Received: SearchHistory", query, limit)
+	if server.history == nil {
+		return []history.Entry{}, nil
+	}
+	return server.history.Search(query, int(limit)), nil
 }
 
-// Kill kills the notification server
+// GetHistoryEntry returns the notification with the given history id. This is a non-standard message
+func (server *Server) GetHistoryEntry(id uint32) (history.Entry, *dbus.Error) {
+	fmt.Println("Received: GetHistoryEntry", id)
+	if server.history != nil {
+		if entry, found := server.history.Get(id); found {
+			return entry, nil
+		}
+	}
+	return history.Entry{}, dbus.NewError(notFoundError, []interface{}{fmt.Sprintf("no history entry %d", id)})
+}
+
+// Kill closes the remaining notifications and kills the notification server
 func (server *Server) Kill() *dbus.Error {
-	gtk.MainQuit()
+	server.lock.Lock()
+	defer server.lock.Unlock()
+
+	server.shutdown()
 	return nil
 }
 
-// Start connects the sever to d-bus to receive messages
-func (server *Server) Start() {
-	handler := DbusHandlerNew(server.commands())
+// shutdown closes the remaining notifications and stops the renderer. It requires the server lock
+func (server *Server) shutdown() {
+	// close the queued ones first, so they are not promoted while the others close
+	for _, state := range []store.State{store.Pending, store.Shown} {
+		for _, entry := range server.store.All() {
+			if entry.State == state {
+				server.close(entry.Notification.ID, schema.Undefined)
+			}
+		}
+	}
+	server.renderer.Quit()
+}
 
-	for {
-		select {
-		case notificationClosed := <-server.NotificationClosedSignal:
-			fmt.Println("Sending NotificationClosed", notificationClosed)
-			go handler.EmitNotificationClosed(notificationClosed)
-		case actionInvoked := <-server.ActionInvokedSignal:
-			fmt.Println("Sending ActionInvoked", actionInvoked)
-			go handler.EmitActionInvoked(actionInvoked)
+func (server *Server) handleEvents() {
+	for event := range server.renderer.Events() {
+		server.lock.Lock()
+		switch event.Type {
+		case render.ActionInvoked:
+			server.invokeAction(event.ID, event.ActionKey)
+		case render.Dismissed:
+			server.close(event.ID, schema.Dismissed)
+		case render.Failed:
+			server.close(event.ID, schema.Undefined)
+		case render.PointerEntered:
+			server.pointerEntered(event.ID)
+		case render.PointerLeft:
+			server.pointerLeft(event.ID)
 		}
+		server.lock.Unlock()
 	}
 }
 
+// Start connects the sever to the session bus to receive messages
+func (server *Server) Start(replace bool) error {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return err
+	}
+	return server.Serve(conn, replace)
+}
+
+// Serve exports the server on conn and blocks emitting its signals. The service name is taken over from the running
+// server if replace is true, and the server shuts down once another one takes it over
+func (server *Server) Serve(conn *dbus.Conn, replace bool) error {
+	server.lock.Lock()
+	server.conn = conn
+	status := server.status()
+	server.lock.Unlock()
+
+	handler, err := DbusHandlerNew(conn, server.commands(), server.vendorCommands(), status, replace)
+	if err != nil {
+		return err
+	}
+	handler.WatchNameLost(func() {
+		server.lock.Lock()
+		defer server.lock.Unlock()
+		server.shutdown()
+	})
+
+	server.lock.Lock()
+	server.published = status
+	server.watch = handler.SetStatus
+	server.publish()
+	server.lock.Unlock()
+	go server.handleEvents()
+
+	for signal := range server.Signals {
+		switch signal := signal.(type) {
+		case schema.NotificationClosed:
+			fmt.Println("Sending NotificationClosed", signal)
+			handler.EmitNotificationClosed(signal)
+		case schema.ActionInvoked:
+			fmt.Println("Sending ActionInvoked", signal)
+			handler.EmitActionInvoked(signal)
+		}
+	}
+	return nil
+}
+
+// commands returns the methods of the org.freedesktop.Notifications interface
 func (server *Server) commands() map[string]interface{} {
 	methodTable := make(map[string]interface{})
 	methodTable["GetServerInformation"] = server.GetServerInformation
 	methodTable["GetCapabilities"] = server.GetCapabilities
 	methodTable["Notify"] = server.Notify
 	methodTable["CloseNotification"] = server.CloseNotification
+	return methodTable
+}
+
+// vendorCommands returns the non-standard methods, exported on the vendor interface
+func (server *Server) vendorCommands() map[string]interface{} {
+	methodTable := make(map[string]interface{})
 	methodTable["CloseLastNotification"] = server.CloseLastNotification
 	methodTable["OpenLastNotification"] = server.OpenLastNotification
 	methodTable["ToggleMute"] = server.ToggleMute
+	methodTable["SetDoNotDisturb"] = server.SetDoNotDisturb
+	methodTable["GetDoNotDisturb"] = server.GetDoNotDisturb
+	methodTable["ListHeld"] = server.ListHeld
+	methodTable["ListHistory"] = server.ListHistory
+	methodTable["SearchHistory"] = server.SearchHistory
+	methodTable["GetHistoryEntry"] = server.GetHistoryEntry
+	methodTable["ListSuppressed"] = server.ListSuppressed
 	methodTable["Kill"] = server.Kill
 	return methodTable
 }
@@ -0,0 +1,49 @@
+package notifyme
+
+import (
+	"testing"
+
+	"github.com/ahirata/notifyme/internal/pkg/render"
+	"github.com/godbus/dbus"
+)
+
+func FuzzNotify(f *testing.F) {
+	f.Add("app", uint32(0), "summary", "default", "Open", byte(2), byte(1), int32(-1), []byte{})
+	f.Add("app", uint32(3), "summary", "key", "label", byte(3), byte(7), int32(0), []byte("0123"))
+	f.Add("", uint32(0), "", "", "", byte(0), byte(9), int32(5000), []byte("\x00\x01"))
+	// a 3x1 RGBA image
+	f.Add("app", uint32(0), "image", "default", "Open", byte(2), byte(1), int32(-1), []byte("0123456789ab"))
+	f.Fuzz(func(t *testing.T, appName string, replacesID uint32, summary, key, label string, length, kind byte, timeout int32, data []byte) {
+		server := ServerNew(render.HeadlessNew(), nil)
+
+		actions := make([]interface{}, int(length)%6)
+		for i := range actions {
+			switch (int(kind) + i) % 3 {
+			case 0:
+				actions[i] = key
+			case 1:
+				actions[i] = label
+			default:
+				actions[i] = data
+			}
+		}
+		// a single row as wide as the data allows, which is valid unless the data is shorter than a pixel
+		channels := 3 + int(kind%2)
+		image := []interface{}{int32(len(data) / channels), int32(1), int32(len(data)), kind%2 == 1, int32(8), int32(channels), data}
+		hints := map[string]dbus.Variant{
+			"urgency":    dbus.MakeVariant(kind),
+			"image-data": dbus.MakeVariant(image),
+		}
+
+		id, err := server.Notify(appName, replacesID, "", summary, string(data), actions, hints, timeout, ":1.1")
+		if err != nil {
+			if err.Name != invalidArgsError {
+				t.Fatalf("unexpected error %s", err.Name)
+			}
+			return
+		}
+		if id == 0 {
+			t.Fatal("notification accepted with id 0")
+		}
+	})
+}
@@ -0,0 +1,752 @@
+package notifyme
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ahirata/notifyme/internal/pkg/config"
+	"github.com/ahirata/notifyme/internal/pkg/dnd"
+	"github.com/ahirata/notifyme/internal/pkg/history"
+	"github.com/ahirata/notifyme/internal/pkg/render"
+	"github.com/ahirata/notifyme/internal/pkg/rules"
+	"github.com/ahirata/notifyme/pkg/notifyme/schema"
+	"github.com/godbus/dbus"
+)
+
+func newTestServer() (*Server, *render.Headless) {
+	renderer := render.HeadlessNew()
+	server := ServerNew(renderer, nil)
+	go server.handleEvents()
+	return server, renderer
+}
+
+func newDedupServer() (*Server, *render.Headless) {
+	server, renderer := newTestServer()
+	configuration := config.Default()
+	configuration.DedupWindow = time.Minute
+	server.Configure(configuration)
+	return server, renderer
+}
+
+func notify(t *testing.T, server *Server, replacesID uint32, summary string, hints map[string]dbus.Variant, expireTimeout int32) uint32 {
+	t.Helper()
+	id, err := server.Notify("test", replacesID, "", summary, "body", []interface{}{"default", "Open"}, hints, expireTimeout, "")
+	if err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	return id
+}
+
+func expectSignal(t *testing.T, server *Server, expected interface{}) {
+	t.Helper()
+	select {
+	case signal := <-server.Signals:
+		if signal != expected {
+			t.Fatalf("expected %+v, got %+v", expected, signal)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for %+v", expected)
+	}
+}
+
+func waitVisible(t *testing.T, renderer *render.Headless, expected int) []render.Popup {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for len(renderer.Visible()) != expected {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d popups, got %d", expected, len(renderer.Visible()))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return renderer.Visible()
+}
+
+func expectNoSignal(t *testing.T, server *Server) {
+	t.Helper()
+	select {
+	case signal := <-server.Signals:
+		t.Fatalf("unexpected signal %+v", signal)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestNotifyAssignsNewIDs(t *testing.T) {
+	server, renderer := newTestServer()
+
+	first := notify(t, server, 0, "first", nil, 0)
+	second := notify(t, server, 0, "second", nil, 0)
+
+	if first == 0 || second <= first {
+		t.Fatalf("expected increasing ids, got %d and %d", first, second)
+	}
+	if visible := renderer.Visible(); len(visible) != 2 {
+		t.Fatalf("expected 2 popups, got %d", len(visible))
+	}
+}
+
+func TestRejectedNotifyKeepsIDs(t *testing.T) {
+	server, _ := newTestServer()
+
+	first := notify(t, server, 0, "first", nil, 0)
+	hints := map[string]dbus.Variant{"urgency": dbus.MakeVariant(byte(7))}
+	if _, err := server.Notify("test", 0, "", "invalid", "body", []interface{}{}, hints, 0, ""); err == nil {
+		t.Fatalf("expected an invalid urgency to be rejected")
+	}
+	if second := notify(t, server, 0, "second", nil, 0); second != first+1 {
+		t.Fatalf("expected id %d after a rejected call, got %d", first+1, second)
+	}
+}
+
+func TestNotifyReplacesExisting(t *testing.T) {
+	server, renderer := newTestServer()
+
+	id := notify(t, server, 0, "original", nil, 0)
+	if replaced := notify(t, server, id, "replaced", nil, 0); replaced != id {
+		t.Fatalf("expected id %d, got %d", id, replaced)
+	}
+
+	visible := renderer.Visible()
+	if len(visible) != 1 || visible[0].Notification.Summary != "replaced" {
+		t.Fatalf("expected the replaced popup only, got %+v", visible)
+	}
+}
+
+func TestNotifyExpires(t *testing.T) {
+	server, renderer := newTestServer()
+
+	id := notify(t, server, 0, "expiring", nil, 10)
+
+	expectSignal(t, server, schema.NotificationClosed{ID: id, Reason: schema.Expired})
+	if _, found := renderer.Get(id); found {
+		t.Fatal("expired popup is still visible")
+	}
+}
+
+func TestCriticalNeverExpires(t *testing.T) {
+	server, renderer := newTestServer()
+
+	id := notify(t, server, 0, "critical", map[string]dbus.Variant{"urgency": dbus.MakeVariant(byte(schema.Critical))}, 10)
+
+	time.Sleep(50 * time.Millisecond)
+	if _, found := renderer.Get(id); !found {
+		t.Fatal("critical popup expired")
+	}
+}
+
+func TestCloseNotification(t *testing.T) {
+	server, renderer := newTestServer()
+
+	id := notify(t, server, 0, "closing", nil, 0)
+	server.CloseNotification(id)
+
+	expectSignal(t, server, schema.NotificationClosed{ID: id, Reason: schema.Closed})
+	if _, found := renderer.Get(id); found {
+		t.Fatal("closed popup is still visible")
+	}
+}
+
+func TestCloseUnknownNotification(t *testing.T) {
+	server, _ := newTestServer()
+
+	server.CloseNotification(42)
+
+	expectNoSignal(t, server)
+}
+
+func TestClosedExactlyOnce(t *testing.T) {
+	server, renderer := newTestServer()
+
+	id := notify(t, server, 0, "closing", nil, 20)
+	server.CloseNotification(id)
+	renderer.Dismiss(id)
+	server.CloseNotification(id)
+
+	expectSignal(t, server, schema.NotificationClosed{ID: id, Reason: schema.Closed})
+	expectNoSignal(t, server)
+}
+
+func TestReplacementCancelsExpiration(t *testing.T) {
+	server, renderer := newTestServer()
+
+	id := notify(t, server, 0, "expiring", nil, 20)
+	notify(t, server, id, "sticky", nil, 0)
+
+	expectNoSignal(t, server)
+	if _, found := renderer.Get(id); !found {
+		t.Fatal("replaced popup expired")
+	}
+}
+
+func TestMutedNotificationsAreClosed(t *testing.T) {
+	server, renderer := newTestServer()
+	server.ToggleMute()
+
+	id := notify(t, server, 0, "muted", nil, 0)
+
+	expectSignal(t, server, schema.NotificationClosed{ID: id, Reason: schema.Undefined})
+	if len(renderer.Visible()) != 0 {
+		t.Fatal("muted notification is visible")
+	}
+}
+
+func TestQuietHoursLetExceptionsThrough(t *testing.T) {
+	server, renderer := newTestServer()
+	configuration := config.Default()
+	configuration.QuietHours = []*dnd.Schedule{dnd.ScheduleNew("always")}
+	configuration.DNDExceptions.Apps = []string{"pager"}
+	server.Configure(configuration)
+
+	held := notify(t, server, 0, "held", nil, 0)
+	expectSignal(t, server, schema.NotificationClosed{ID: held, Reason: schema.Undefined})
+
+	critical := notify(t, server, 0, "critical", map[string]dbus.Variant{"urgency": dbus.MakeVariant(byte(schema.Critical))}, 0)
+	allowed, err := server.Notify("pager", 0, "", "allowed", "body", nil, nil, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []uint32{critical, allowed} {
+		if _, found := renderer.Get(id); !found {
+			t.Fatalf("notification %d was held back", id)
+		}
+	}
+
+	server.SetDoNotDisturb("off", 0)
+	shown := notify(t, server, 0, "shown", nil, 0)
+	if _, found := renderer.Get(shown); !found {
+		t.Fatal("turning do not disturb off did not override the quiet hours")
+	}
+
+	server.SetDoNotDisturb("off", time.Now().Add(-time.Second).Unix())
+	expired := notify(t, server, 0, "expired", nil, 0)
+	expectSignal(t, server, schema.NotificationClosed{ID: expired, Reason: schema.Undefined})
+
+	if err := server.SetDoNotDisturb("sometimes", 0); err == nil {
+		t.Fatal("expected an error for an unknown mode")
+	}
+}
+
+func TestMissedNotificationsSummary(t *testing.T) {
+	server, renderer := newTestServer()
+	server.SetDoNotDisturb("on", 0)
+
+	var held []uint32
+	for _, app := range []string{"chat", "mail", "chat"} {
+		id, err := server.Notify(app, 0, "", "from "+app, "body", []interface{}{"default", "Open"}, nil, 0, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectSignal(t, server, schema.NotificationClosed{ID: id, Reason: schema.Undefined})
+		held = append(held, id)
+	}
+
+	server.SetDoNotDisturb("auto", 0)
+	visible := renderer.Visible()
+	if len(visible) != 1 {
+		t.Fatalf("expected a single summary, got %d popups", len(visible))
+	}
+	summary := visible[0].Notification
+	if summary.Summary != "3 notifications while muted" || summary.Body != "2 from chat, 1 from mail" {
+		t.Fatalf("unexpected summary %q: %q", summary.Summary, summary.Body)
+	}
+
+	renderer.InvokeAction(summary.ID, expandAction)
+	visible = waitVisible(t, renderer, 3)
+
+	renderer.InvokeAction(visible[1].Notification.ID, replayAction)
+	time.Sleep(20 * time.Millisecond)
+	visible = renderer.Visible()
+	if len(visible) != 3 || visible[2].Notification.Summary != "from mail" {
+		t.Fatalf("the missed notification was not replayed, got %+v", visible)
+	}
+	for _, id := range held {
+		if _, found := renderer.Get(id); found {
+			t.Fatalf("the replay took the id %d of a closed notification", id)
+		}
+	}
+
+	// the clients are told nothing about the popups of the server itself
+	renderer.InvokeAction(visible[2].Notification.ID, "default")
+	expectNoSignal(t, server)
+}
+
+func TestReplayKeepsReplacements(t *testing.T) {
+	server, renderer := newTestServer()
+	server.SetDoNotDisturb("on", 0)
+	held := notify(t, server, 0, "held v1", nil, 0)
+	expectSignal(t, server, schema.NotificationClosed{ID: held, Reason: schema.Undefined})
+	server.SetDoNotDisturb("auto", 0)
+
+	summary := renderer.Visible()[0].Notification.ID
+	notify(t, server, held, "fresh v2", nil, 0)
+	renderer.InvokeAction(summary, expandAction)
+	time.Sleep(20 * time.Millisecond)
+	listed := renderer.Visible()[1].Notification.ID
+	renderer.InvokeAction(listed, replayAction)
+	time.Sleep(20 * time.Millisecond)
+
+	if popup, _ := renderer.Get(held); popup.Notification.Summary != "fresh v2" {
+		t.Fatalf("the replay overwrote the live notification with %q", popup.Notification.Summary)
+	}
+	if visible := renderer.Visible(); len(visible) != 2 || visible[1].Notification.Summary != "held v1" {
+		t.Fatalf("expected the replay next to the live notification, got %+v", visible)
+	}
+	expectNoSignal(t, server)
+}
+
+func TestMissedNotificationsAreListedInPages(t *testing.T) {
+	server, renderer := newTestServer()
+	server.SetDoNotDisturb("on", 0)
+	for i := 0; i < missedPage+2; i++ {
+		id := notify(t, server, 0, "missed", nil, 0)
+		expectSignal(t, server, schema.NotificationClosed{ID: id, Reason: schema.Undefined})
+	}
+	server.SetDoNotDisturb("auto", 0)
+
+	renderer.InvokeAction(renderer.Visible()[0].Notification.ID, expandAction)
+	visible := waitVisible(t, renderer, missedPage+1)
+	rest := visible[missedPage].Notification
+	if rest.Summary != "2 more notifications while muted" {
+		t.Fatalf("expected a summary of the rest, got %q", rest.Summary)
+	}
+
+	renderer.InvokeAction(rest.ID, expandAction)
+	waitVisible(t, renderer, missedPage+2)
+	expectNoSignal(t, server)
+}
+
+func TestInvokeAction(t *testing.T) {
+	server, renderer := newTestServer()
+
+	id := notify(t, server, 0, "action", nil, 0)
+	renderer.InvokeAction(id, "default")
+
+	expectSignal(t, server, schema.ActionInvoked{ID: id, ActionKey: "default"})
+	expectSignal(t, server, schema.NotificationClosed{ID: id, Reason: schema.Dismissed})
+	if _, found := renderer.Get(id); found {
+		t.Fatal("popup is still visible after the action")
+	}
+}
+
+func TestInvokeActionOnResident(t *testing.T) {
+	server, renderer := newTestServer()
+
+	id := notify(t, server, 0, "resident", map[string]dbus.Variant{"resident": dbus.MakeVariant(true)}, 0)
+	renderer.InvokeAction(id, "default")
+
+	expectSignal(t, server, schema.ActionInvoked{ID: id, ActionKey: "default"})
+	expectNoSignal(t, server)
+}
+
+func TestConfigureKeepsVisiblePopups(t *testing.T) {
+	server, renderer := newTestServer()
+	id := notify(t, server, 0, "visible", nil, 0)
+
+	configuration := config.Default()
+	configuration.Timeout = 10
+	configuration.Capabilities = []string{"body"}
+	server.Configure(configuration)
+
+	if capabilities, _ := server.GetCapabilities(); len(capabilities) != 1 || capabilities[0] != "body" {
+		t.Fatalf("unexpected capabilities %v", capabilities)
+	}
+	if renderer.Config() != configuration {
+		t.Fatal("configuration was not passed to the renderer")
+	}
+	if _, found := renderer.Get(id); !found {
+		t.Fatal("popup was dropped on reload")
+	}
+
+	expiring := notify(t, server, 0, "default timeout", nil, -1)
+	expectSignal(t, server, schema.NotificationClosed{ID: expiring, Reason: schema.Expired})
+}
+
+func TestRules(t *testing.T) {
+	server, renderer := newTestServer()
+	configuration := config.Default()
+	for _, settings := range [][]string{
+		{"ci", "app-name", "test", "add-class", "ci"},
+		{"quiet", "summary", "^quiet", "suppress", "true"},
+	} {
+		rule := rules.RuleNew(settings[0])
+		rule.Set(settings[1], settings[2])
+		rule.Set(settings[3], settings[4])
+		configuration.Rules = append(configuration.Rules, rule)
+	}
+	server.Configure(configuration)
+
+	shown := notify(t, server, 0, "loud", nil, 0)
+	if popup, _ := renderer.Get(shown); len(popup.Classes) != 1 || popup.Classes[0] != "ci" {
+		t.Fatalf("unexpected popup %+v", popup)
+	}
+
+	suppressed := notify(t, server, 0, "quiet please", nil, 0)
+	expectSignal(t, server, schema.NotificationClosed{ID: suppressed, Reason: schema.Undefined})
+	if _, found := renderer.Get(suppressed); found {
+		t.Fatal("suppressed notification is visible")
+	}
+}
+
+func TestOverflowQueue(t *testing.T) {
+	server, renderer := newTestServer()
+	configuration := config.Default()
+	configuration.MaxVisible = 2
+	server.Configure(configuration)
+
+	first := notify(t, server, 0, "first", nil, 0)
+	notify(t, server, 0, "second", nil, 0)
+	low := notify(t, server, 0, "low", map[string]dbus.Variant{"urgency": dbus.MakeVariant(byte(schema.Low))}, 0)
+	critical := notify(t, server, 0, "critical", map[string]dbus.Variant{"urgency": dbus.MakeVariant(byte(schema.Critical))}, 0)
+
+	if visible := renderer.Visible(); len(visible) != 2 {
+		t.Fatalf("expected 2 popups, got %d", len(visible))
+	}
+	if queued := renderer.Queued(); queued != 2 {
+		t.Fatalf("expected 2 queued, got %d", queued)
+	}
+
+	server.CloseNotification(first)
+	expectSignal(t, server, schema.NotificationClosed{ID: first, Reason: schema.Closed})
+	if _, found := renderer.Get(critical); !found {
+		t.Fatal("the most urgent notification was not promoted")
+	}
+	if queued := renderer.Queued(); queued != 1 {
+		t.Fatalf("expected 1 queued, got %d", queued)
+	}
+
+	server.CloseNotification(low)
+	expectSignal(t, server, schema.NotificationClosed{ID: low, Reason: schema.Closed})
+	if queued := renderer.Queued(); queued != 0 {
+		t.Fatalf("expected an empty queue, got %d", queued)
+	}
+}
+
+func TestQueuedNotificationsExpireOnceShown(t *testing.T) {
+	server, renderer := newTestServer()
+	configuration := config.Default()
+	configuration.MaxVisible = 1
+	server.Configure(configuration)
+
+	first := notify(t, server, 0, "first", nil, 0)
+	queued := notify(t, server, 0, "queued", nil, 10)
+	expectNoSignal(t, server)
+
+	server.CloseNotification(first)
+	expectSignal(t, server, schema.NotificationClosed{ID: first, Reason: schema.Closed})
+	if _, found := renderer.Get(queued); !found {
+		t.Fatal("queued notification was not promoted")
+	}
+	expectSignal(t, server, schema.NotificationClosed{ID: queued, Reason: schema.Expired})
+}
+
+func TestMirror(t *testing.T) {
+	server, renderer := newTestServer()
+	configuration := config.Default()
+	configuration.Mirror = []schema.Urgency{schema.Critical}
+	rule := rules.RuleNew("presentation")
+	rule.Set("summary", "^slides")
+	rule.Set("mirror", "true")
+	configuration.Rules = append(configuration.Rules, rule)
+	server.Configure(configuration)
+
+	critical := notify(t, server, 0, "critical", map[string]dbus.Variant{"urgency": dbus.MakeVariant(byte(schema.Critical))}, 0)
+	normal := notify(t, server, 0, "normal", nil, 0)
+	slides := notify(t, server, 0, "slides", nil, 0)
+
+	for id, expected := range map[uint32]bool{critical: true, normal: false, slides: true} {
+		if popup, _ := renderer.Get(id); popup.Mirror != expected {
+			t.Fatalf("expected mirror %t for %q", expected, popup.Notification.Summary)
+		}
+	}
+
+	renderer.InvokeAction(critical, "default")
+	renderer.InvokeAction(critical, "default")
+	expectSignal(t, server, schema.ActionInvoked{ID: critical, ActionKey: "default"})
+	expectSignal(t, server, schema.NotificationClosed{ID: critical, Reason: schema.Dismissed})
+	expectNoSignal(t, server)
+}
+
+func TestHoverPausesExpiration(t *testing.T) {
+	server, renderer := newTestServer()
+	configuration := config.Default()
+	configuration.ResumeTimeout = 100
+	server.Configure(configuration)
+
+	id := notify(t, server, 0, "hovered", nil, 30)
+	renderer.Hover(id)
+	time.Sleep(60 * time.Millisecond)
+	expectNoSignal(t, server)
+
+	renderer.Leave(id)
+	expectNoSignal(t, server)
+	expectSignal(t, server, schema.NotificationClosed{ID: id, Reason: schema.Expired})
+}
+
+func TestReplacementWhileHoveredStaysPaused(t *testing.T) {
+	server, renderer := newTestServer()
+	configuration := config.Default()
+	configuration.ResumeTimeout = 0
+	server.Configure(configuration)
+
+	id := notify(t, server, 0, "hovered", nil, 30)
+	renderer.Hover(id)
+	time.Sleep(10 * time.Millisecond)
+	notify(t, server, id, "replaced", nil, 30)
+	time.Sleep(60 * time.Millisecond)
+	expectNoSignal(t, server)
+
+	renderer.Leave(id)
+	expectSignal(t, server, schema.NotificationClosed{ID: id, Reason: schema.Expired})
+}
+
+func TestIdleFreezesExpiration(t *testing.T) {
+	server, _ := newTestServer()
+	configuration := config.Default()
+	configuration.ResumeTimeout = 0
+	server.Configure(configuration)
+
+	server.SetIdle(true)
+	frozen := notify(t, server, 0, "while away", nil, 20)
+	time.Sleep(40 * time.Millisecond)
+	expectNoSignal(t, server)
+
+	server.SetIdle(false)
+	expectSignal(t, server, schema.NotificationClosed{ID: frozen, Reason: schema.Expired})
+}
+
+func TestRateLimitSummarizes(t *testing.T) {
+	server, renderer := newTestServer()
+	configuration := config.Default()
+	configuration.AppRate = 0.001
+	configuration.AppBurst = 2
+	server.Configure(configuration)
+
+	first := notify(t, server, 0, "first", nil, 0)
+	notify(t, server, 0, "second", nil, 0)
+	if replaced := notify(t, server, first, "replacements are not limited", nil, 0); replaced != first {
+		t.Fatalf("expected id %d, got %d", first, replaced)
+	}
+
+	suppressed := notify(t, server, 0, "third", nil, 0)
+	expectSignal(t, server, schema.NotificationClosed{ID: suppressed, Reason: schema.Undefined})
+	summary := renderer.Visible()[2]
+	if summary.Notification.Summary != "1 notifications suppressed from test" {
+		t.Fatalf("unexpected summary %q", summary.Notification.Summary)
+	}
+
+	suppressed = notify(t, server, 0, "fourth", nil, 0)
+	expectSignal(t, server, schema.NotificationClosed{ID: suppressed, Reason: schema.Undefined})
+	if visible := renderer.Visible(); len(visible) != 3 || visible[2].Notification.Summary != "2 notifications suppressed from test" {
+		t.Fatalf("expected the summary to be updated, got %+v", visible)
+	}
+
+	suppressions, _ := server.ListSuppressed()
+	if len(suppressions) != 1 || suppressions[0].Count != 2 {
+		t.Fatalf("unexpected suppressions %+v", suppressions)
+	}
+}
+
+func TestRateLimitIsSummarizedAfterDoNotDisturb(t *testing.T) {
+	server, renderer := newTestServer()
+	configuration := config.Default()
+	configuration.AppRate = 0.001
+	configuration.AppBurst = 1
+	server.Configure(configuration)
+	server.SetDoNotDisturb("on", 0)
+
+	for _, summary := range []string{"held", "suppressed"} {
+		id := notify(t, server, 0, summary, nil, 0)
+		expectSignal(t, server, schema.NotificationClosed{ID: id, Reason: schema.Undefined})
+	}
+	if visible := renderer.Visible(); len(visible) != 0 {
+		t.Fatalf("expected nothing shown while muted, got %+v", visible)
+	}
+
+	server.SetDoNotDisturb("auto", 0)
+	if visible := renderer.Visible(); len(visible) != 1 || visible[0].Notification.Summary != "2 notifications while muted" {
+		t.Fatalf("expected the suppressed notification in the missed summary, got %+v", visible)
+	}
+}
+
+func TestRateLimitKeepsHistory(t *testing.T) {
+	notificationHistory, err := history.HistoryNew(t.TempDir(), 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renderer := render.HeadlessNew()
+	server := ServerNew(renderer, notificationHistory)
+	go server.handleEvents()
+	configuration := config.Default()
+	configuration.HistoryMaxEntries = 10
+	configuration.AppRate = 0.001
+	configuration.AppBurst = 1
+	server.Configure(configuration)
+
+	notify(t, server, 0, "kept", nil, 0)
+	for i := 0; i < 2*configuration.HistoryMaxEntries; i++ {
+		suppressed := notify(t, server, 0, "flood", nil, 0)
+		expectSignal(t, server, schema.NotificationClosed{ID: suppressed, Reason: schema.Undefined})
+	}
+	if entries := notificationHistory.List(0, 0); len(entries) != 1 || entries[0].Summary != "kept" {
+		t.Fatalf("expected the flood to stay out of the history, got %+v", entries)
+	}
+}
+
+func TestReloadKeepsRateLimits(t *testing.T) {
+	server, _ := newTestServer()
+	configuration := config.Default()
+	configuration.AppRate = 0.001
+	configuration.AppBurst = 1
+	server.Configure(configuration)
+
+	notify(t, server, 0, "first", nil, 0)
+	reloaded := *configuration
+	reloaded.Timeout = 5000
+	server.Configure(&reloaded)
+	suppressed := notify(t, server, 0, "second", nil, 0)
+	expectSignal(t, server, schema.NotificationClosed{ID: suppressed, Reason: schema.Undefined})
+}
+
+func TestRateLimitDrops(t *testing.T) {
+	server, renderer := newTestServer()
+	configuration := config.Default()
+	configuration.AppRate = 0.001
+	configuration.AppBurst = 1
+	configuration.Excess = config.ExcessDrop
+	server.Configure(configuration)
+
+	notify(t, server, 0, "first", nil, 0)
+	dropped := notify(t, server, 0, "dropped", nil, 0)
+	expectSignal(t, server, schema.NotificationClosed{ID: dropped, Reason: schema.Undefined})
+	if visible := renderer.Visible(); len(visible) != 1 {
+		t.Fatalf("expected no summary, got %+v", visible)
+	}
+}
+
+func TestDuplicatesAreMerged(t *testing.T) {
+	server, renderer := newDedupServer()
+
+	first := notify(t, server, 0, "build failed", nil, 0)
+	second := notify(t, server, 0, "build failed", nil, 0)
+	third := notify(t, server, 0, "build failed", nil, 0)
+	if first == second || second == third {
+		t.Fatalf("expected distinct ids, got %d, %d and %d", first, second, third)
+	}
+
+	visible := renderer.Visible()
+	if len(visible) != 1 || visible[0].Repeats != 3 {
+		t.Fatalf("expected a single popup repeated 3 times, got %+v", visible)
+	}
+
+	server.CloseNotification(second)
+	expectSignal(t, server, schema.NotificationClosed{ID: second, Reason: schema.Closed})
+	if popup, _ := renderer.Get(first); popup.Repeats != 2 {
+		t.Fatalf("expected 2 repeats left, got %d", popup.Repeats)
+	}
+
+	renderer.Dismiss(first)
+	expectSignal(t, server, schema.NotificationClosed{ID: first, Reason: schema.Dismissed})
+	expectSignal(t, server, schema.NotificationClosed{ID: third, Reason: schema.Dismissed})
+	expectNoSignal(t, server)
+}
+
+func TestMergedNotificationsGetActions(t *testing.T) {
+	server, renderer := newDedupServer()
+
+	first := notify(t, server, 0, "new mail", nil, 0)
+	second := notify(t, server, 0, "new mail", nil, 0)
+	renderer.InvokeAction(first, "default")
+	expectSignal(t, server, schema.ActionInvoked{ID: first, ActionKey: "default"})
+	expectSignal(t, server, schema.ActionInvoked{ID: second, ActionKey: "default"})
+	expectSignal(t, server, schema.NotificationClosed{ID: first, Reason: schema.Dismissed})
+	expectSignal(t, server, schema.NotificationClosed{ID: second, Reason: schema.Dismissed})
+}
+
+func TestMergeRestartsExpiration(t *testing.T) {
+	server, _ := newDedupServer()
+
+	first := notify(t, server, 0, "repeated", nil, 200)
+	time.Sleep(120 * time.Millisecond)
+	second := notify(t, server, 0, "repeated", nil, 200)
+	time.Sleep(100 * time.Millisecond)
+	expectNoSignal(t, server)
+	expectSignal(t, server, schema.NotificationClosed{ID: first, Reason: schema.Expired})
+	expectSignal(t, server, schema.NotificationClosed{ID: second, Reason: schema.Expired})
+}
+
+func TestReplacingMergedNotificationSplitsIt(t *testing.T) {
+	server, renderer := newDedupServer()
+
+	first := notify(t, server, 0, "progress", nil, 0)
+	second := notify(t, server, 0, "progress", nil, 0)
+	notify(t, server, second, "progress 50%", nil, 0)
+
+	if visible := renderer.Visible(); len(visible) != 2 || visible[0].Repeats != 1 || visible[1].Notification.ID != second {
+		t.Fatalf("expected two separate popups, got %+v", visible)
+	}
+	server.CloseNotification(first)
+	expectSignal(t, server, schema.NotificationClosed{ID: first, Reason: schema.Closed})
+	expectNoSignal(t, server)
+}
+
+func TestDuplicatesAreRateLimited(t *testing.T) {
+	server, renderer := newTestServer()
+	configuration := config.Default()
+	configuration.DedupWindow = time.Minute
+	configuration.AppRate = 0.001
+	configuration.AppBurst = 1
+	configuration.Excess = config.ExcessDrop
+	server.Configure(configuration)
+
+	first := notify(t, server, 0, "flood", nil, 0)
+	dropped := notify(t, server, 0, "flood", nil, 0)
+	expectSignal(t, server, schema.NotificationClosed{ID: dropped, Reason: schema.Undefined})
+	if popup, _ := renderer.Get(first); popup.Repeats != 1 {
+		t.Fatalf("expected the duplicate over the limit not to be merged, got %d repeats", popup.Repeats)
+	}
+}
+
+func TestMergesAreCapped(t *testing.T) {
+	server, renderer := newDedupServer()
+
+	first := notify(t, server, 0, "flood", nil, 0)
+	oldest := notify(t, server, 0, "flood", nil, 0)
+	for i := 0; i < maxMerged; i++ {
+		notify(t, server, 0, "flood", nil, 0)
+	}
+	expectSignal(t, server, schema.NotificationClosed{ID: oldest, Reason: schema.Undefined})
+	if popup, _ := renderer.Get(first); popup.Repeats != maxMerged+1 {
+		t.Fatalf("expected %d repeats, got %d", maxMerged+1, popup.Repeats)
+	}
+}
+
+func TestGroupTakesASinglePlace(t *testing.T) {
+	server, renderer := newTestServer()
+	configuration := config.Default()
+	configuration.MaxVisible = 1
+	configuration.GroupBy = config.GroupApp
+	server.Configure(configuration)
+
+	first := notify(t, server, 0, "first", nil, 0)
+	second := notify(t, server, 0, "second", nil, 0)
+	other := notify(t, server, 0, "other", map[string]dbus.Variant{"desktop-entry": dbus.MakeVariant("other")}, 0)
+
+	for _, id := range []uint32{first, second} {
+		popup, found := renderer.Get(id)
+		if !found {
+			t.Fatalf("notification %d of the stack is not visible", id)
+		}
+		if popup.Group != "test" {
+			t.Fatalf("expected the popup in the test group, got %q", popup.Group)
+		}
+	}
+	if _, found := renderer.Get(other); found {
+		t.Fatal("a notification from another app was shown over the limit")
+	}
+
+	renderer.DismissGroup("test")
+	expectSignal(t, server, schema.NotificationClosed{ID: first, Reason: schema.Dismissed})
+	expectSignal(t, server, schema.NotificationClosed{ID: second, Reason: schema.Dismissed})
+	if popup, found := renderer.Get(other); !found || popup.Group != "other" {
+		t.Fatal("the next stack was not promoted")
+	}
+}
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"time"
+
+	"github.com/ahirata/notifyme/pkg/notifyme/schema"
+)
+
+// Lifecycle states of a notification
+const (
+	// Pending notifications were accepted but are not displayed yet
+	Pending State = iota
+	// Shown notifications are being displayed
+	Shown
+	// Closing notifications are being taken down and must not be touched anymore
+	Closing
+	// Closed notifications are gone and had their NotificationClosed emitted
+	Closed
+)
+
+// State is a step in the lifecycle of a notification
+type State int
+
+var transitions = map[State][]State{
+	Pending: {Shown, Closing},
+	Shown:   {Closing},
+	Closing: {Closed},
+}
+
+func (state State) String() string {
+	switch state {
+	case Pending:
+		return "pending"
+	case Shown:
+		return "shown"
+	case Closing:
+		return "closing"
+	default:
+		return "closed"
+	}
+}
+
+// Entry is a notification along with its lifecycle state
+type Entry struct {
+	Notification *schema.Notification
+	State        State
+	// Classes are the style classes added by the rules
+	Classes []string
+	// Mirror tells whether the notification is shown on every monitor
+	Mirror bool
+	// Group is the stack the notification belongs to, empty if none
+	Group string
+	// Merged holds the ids of the identical notifications merged into this one, and Updated when the last one arrived
+	Merged  []uint32
+	Updated time.Time
+	// Internal tells the popup comes from the server itself, so no client is told about it
+	Internal bool
+}
+
+// Transition moves the entry to the given state, returning false if the move is not allowed
+func (entry *Entry) Transition(state State) bool {
+	for _, allowed := range transitions[entry.State] {
+		if allowed == state {
+			entry.State = state
+			return true
+		}
+	}
+	return false
+}
+
+// Repeats returns how many notifications the entry stands for, itself included
+func (entry *Entry) Repeats() int {
+	return 1 + len(entry.Merged)
+}
+
+// Unmerge forgets a notification merged into this one, returning false if it was not
+func (entry *Entry) Unmerge(id uint32) bool {
+	for i, merged := range entry.Merged {
+		if merged == id {
+			entry.Merged = append(entry.Merged[:i:i], entry.Merged[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// IsOpen returns true while the notification has not started closing
+func (entry *Entry) IsOpen() bool {
+	return entry.State == Pending || entry.State == Shown
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"time"
+
+	"github.com/ahirata/notifyme/pkg/notifyme/schema"
+)
+
+// NotificationStore holds the notifications that have not been closed yet
+type NotificationStore struct {
+	entries []*Entry
+}
+
+// Push adds a pending notification to the list
+func (store *NotificationStore) Push(notification *schema.Notification) *Entry {
+	entry := &Entry{Notification: notification, State: Pending, Updated: time.Now()}
+	store.entries = append(store.entries, entry)
+	return entry
+}
+
+// Last returns the most recent notification being shown
+func (store *NotificationStore) Last() *Entry {
+	for i := len(store.entries) - 1; i >= 0; i-- {
+		if store.entries[i].State == Shown {
+			return store.entries[i]
+		}
+	}
+	return nil
+}
+
+// Remove removes a notification based on its id regardless of its position in the list
+func (store *NotificationStore) Remove(id uint32) *Entry {
+	filtered := store.entries[:0]
+	var removed *Entry
+	for _, entry := range store.entries {
+		if entry.Notification.ID != id {
+			filtered = append(filtered, entry)
+		} else {
+			removed = entry
+		}
+	}
+	store.entries = filtered
+	return removed
+}
+
+// Get retrieves the notification by id
+func (store *NotificationStore) Get(id uint32) *Entry {
+	for _, entry := range store.entries {
+		if entry.Notification.ID == id {
+			return entry
+		}
+	}
+	return nil
+}
+
+// Duplicate returns the open notification with the same app, summary and body as the given one, if it was
+// last updated after since
+func (store *NotificationStore) Duplicate(notification *schema.Notification, since time.Time) *Entry {
+	for i := len(store.entries) - 1; i >= 0; i-- {
+		entry := store.entries[i]
+		if entry.IsOpen() && !entry.Internal && entry.Updated.After(since) &&
+			entry.Notification.AppName == notification.AppName &&
+			entry.Notification.Summary == notification.Summary &&
+			entry.Notification.Body == notification.Body {
+			return entry
+		}
+	}
+	return nil
+}
+
+// Owner returns the open notification that the one with the given id was merged into
+func (store *NotificationStore) Owner(id uint32) *Entry {
+	for _, entry := range store.entries {
+		for _, merged := range entry.Merged {
+			if merged == id && entry.IsOpen() {
+				return entry
+			}
+		}
+	}
+	return nil
+}
+
+// NextPending returns the pending notification with the highest urgency, the oldest one among equals
+func (store *NotificationStore) NextPending() *Entry {
+	var next *Entry
+	for _, entry := range store.entries {
+		if entry.State == Pending && (next == nil || entry.Notification.Urgency > next.Notification.Urgency) {
+			next = entry
+		}
+	}
+	return next
+}
+
+// Count returns the number of notifications in the given state
+func (store *NotificationStore) Count(state State) int {
+	count := 0
+	for _, entry := range store.entries {
+		if entry.State == state {
+			count++
+		}
+	}
+	return count
+}
+
+// All returns the entries, oldest first
+func (store *NotificationStore) All() []*Entry {
+	return append([]*Entry(nil), store.entries...)
+}
+
+// IsEmpty returns true if there are no notifications, false otherwise
+func (store *NotificationStore) IsEmpty() bool {
+	return len(store.entries) == 0
+}
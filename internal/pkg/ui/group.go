@@ -0,0 +1,68 @@
+package ui
+
+import (
+	"github.com/ahirata/notifyme/internal/pkg/render"
+)
+
+// WidgetGroup holds the linked widgets showing a notification: a single one on the chosen monitor,
+// or one per monitor when the popup is mirrored. They all report their clicks with the same notification ID
+type WidgetGroup struct {
+	Popup   render.Popup
+	Widgets []*NotificationWidget
+}
+
+// copies returns how many widgets the group needs with the given number of monitors
+func (group *WidgetGroup) copies(monitors int) int {
+	if group.Popup.Mirror && monitors > 1 {
+		return monitors
+	}
+	return 1
+}
+
+// widget returns the copy shown on the given output, if any
+func (group *WidgetGroup) widget(output int, selected int) *NotificationWidget {
+	if !group.Popup.Mirror {
+		if output == selected && len(group.Widgets) > 0 {
+			return group.Widgets[0]
+		}
+		return nil
+	}
+	if output < len(group.Widgets) {
+		return group.Widgets[output]
+	}
+	return nil
+}
+
+// setStack updates the stack bar of every copy
+func (group *WidgetGroup) setStack(count int, expanded bool) {
+	for _, widget := range group.Widgets {
+		widget.setStack(count, expanded)
+	}
+}
+
+// setHidden hides every copy while the group is collapsed into a newer popup of its stack
+func (group *WidgetGroup) setHidden(hidden bool) {
+	for _, widget := range group.Widgets {
+		if hidden {
+			widget.Window.Hide()
+		} else if !widget.Window.GetVisible() {
+			widget.Window.Show()
+		}
+	}
+}
+
+// Update replaces the contents of every copy
+func (group *WidgetGroup) Update(popup render.Popup) {
+	group.Popup = popup
+	for _, widget := range group.Widgets {
+		widget.ReplaceNotification(popup)
+	}
+}
+
+// Close destroys every copy
+func (group *WidgetGroup) Close() {
+	for _, widget := range group.Widgets {
+		widget.Close()
+	}
+	group.Widgets = nil
+}
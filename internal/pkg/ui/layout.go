@@ -0,0 +1,81 @@
+package ui
+
+import (
+	"fmt"
+	"github.com/ahirata/notifyme/internal/pkg/layout"
+	"github.com/gotk3/gotk3/gdk"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// reflow repacks the windows of every monitor in the configured order, with the queue indicator always
+// the farthest from the anchor on the chosen monitor. It runs whenever a window is added, removed or resized
+func (renderer *Renderer) reflow() {
+	outputs, err := getOutputs()
+	if err != nil {
+		fmt.Println("Error listing the monitors", err)
+		return
+	}
+	selected, found := renderer.config.Monitor.Select(outputs)
+	if !found {
+		return
+	}
+
+	groups := renderer.arrange()
+	for i, output := range outputs {
+		var windows []*gtk.Window
+		for j := range groups {
+			if renderer.config.Stacking == layout.NewestNearest {
+				j = len(groups) - 1 - j
+			}
+			if widget := groups[j].widget(i, selected); widget != nil {
+				windows = append(windows, widget.Window)
+			}
+		}
+		if i == selected && renderer.more != nil {
+			windows = append(windows, renderer.more.Window)
+		}
+		renderer.place(windows, output.Workarea)
+	}
+}
+
+// place stacks the windows on the workarea
+func (renderer *Renderer) place(windows []*gtk.Window, workarea layout.Rectangle) {
+	sizes := make([]layout.Size, len(windows))
+	for i, window := range windows {
+		sizes[i] = layout.Size{Width: window.GetAllocatedWidth(), Height: window.GetAllocatedHeight()}
+	}
+	settings := layout.Settings{
+		Anchor:  renderer.config.Anchor,
+		MarginX: renderer.config.OffsetX,
+		MarginY: renderer.config.OffsetY,
+		Spacing: renderer.config.Spacing,
+	}
+
+	for i, position := range layout.Stack(workarea, sizes, settings) {
+		if x, y := windows[i].GetPosition(); x != position.X || y != position.Y {
+			windows[i].Move(position.X, position.Y)
+		}
+	}
+}
+
+// watchMonitors adds or removes the copies of the mirrored popups and repacks them when monitors are plugged,
+// unplugged or resized
+func (renderer *Renderer) watchMonitors() {
+	screen, err := gdk.ScreenGetDefault()
+	if err != nil {
+		fmt.Println("Error watching the monitors", err)
+		return
+	}
+	screen.Connect("monitors-changed", renderer.monitorsChanged)
+	screen.Connect("size-changed", renderer.reflow)
+}
+
+func (renderer *Renderer) monitorsChanged() {
+	monitors := monitorCount()
+	for _, group := range renderer.groups {
+		if err := renderer.resize(group, monitors); err != nil {
+			fmt.Println("Error building widget", err)
+		}
+	}
+	renderer.reflow()
+}
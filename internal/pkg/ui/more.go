@@ -0,0 +1,47 @@
+package ui
+
+import (
+	"fmt"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// MoreWidget tells how many notifications are waiting for room on the screen
+type MoreWidget struct {
+	Window *gtk.Window
+	Label  *gtk.Label
+}
+
+// MoreWidgetNew creates the indicator
+func MoreWidgetNew() (*MoreWidget, error) {
+	var err error
+	more := MoreWidget{}
+	if more.Window, err = gtk.WindowNew(gtk.WINDOW_POPUP); err != nil {
+		return nil, err
+	}
+	if more.Label, err = gtk.LabelNew(""); err != nil {
+		return nil, err
+	}
+
+	configureWindow(more.Window)
+	LoadCSSProvider(more.Window)
+	AddClass(more.Window, "notifyme")
+	AddClass(more.Window, "more")
+	box, err := AddBox(more.Window, gtk.ORIENTATION_VERTICAL, "main")
+	if err != nil {
+		return nil, err
+	}
+	box.Add(more.Label)
+
+	return &more, nil
+}
+
+// Update shows the number of queued notifications
+func (more *MoreWidget) Update(count int) {
+	more.Label.SetText(fmt.Sprintf("+%d more", count))
+	more.Window.ShowAll()
+}
+
+// Close destroys the indicator
+func (more *MoreWidget) Close() {
+	more.Window.Destroy()
+}
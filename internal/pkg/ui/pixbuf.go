@@ -1,18 +1,26 @@
 package ui
 
 import (
+	"errors"
 	"github.com/gotk3/gotk3/gdk"
 	"strings"
 )
 
-func pixbufNewFromData(data []byte, colorspace gdk.Colorspace, hasAlpha bool, bitsPerSample, originalWidth, originalHeight, desiredWidth, desiredHeight int) (*gdk.Pixbuf, error) {
+func pixbufNewFromData(data []byte, colorspace gdk.Colorspace, hasAlpha bool, bitsPerSample, rowStride, originalWidth, originalHeight, desiredWidth, desiredHeight int) (*gdk.Pixbuf, error) {
 	pixbuf, err := gdk.PixbufNew(colorspace, hasAlpha, bitsPerSample, originalWidth, originalHeight)
 	if err != nil {
 		return nil, err
 	}
+	rowLength := originalWidth * pixbuf.GetNChannels()
+	if rowStride < rowLength || len(data) < rowStride*(originalHeight-1)+rowLength {
+		return nil, errors.New("not enough image data")
+	}
+
+	// the source and the pixbuf may pad their rows differently, so copy row by row
 	pixels := pixbuf.GetPixels()
-	for i := 0; i < len(pixels); i++ {
-		pixels[i] = data[i]
+	for row := 0; row < originalHeight; row++ {
+		source := data[row*rowStride : row*rowStride+rowLength]
+		copy(pixels[row*pixbuf.GetRowstride():], source)
 	}
 
 	return pixbuf.ScaleSimple(desiredWidth, desiredHeight, gdk.INTERP_BILINEAR)
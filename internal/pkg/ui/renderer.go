@@ -0,0 +1,166 @@
+package ui
+
+import (
+	"fmt"
+	"github.com/ahirata/notifyme/internal/pkg/config"
+	"github.com/ahirata/notifyme/internal/pkg/render"
+	"github.com/gotk3/gotk3/glib"
+	"github.com/gotk3/gotk3/gtk"
+)
+
+// Renderer displays notifications as GTK popups. Every call is scheduled on the GTK main loop
+type Renderer struct {
+	groups   []*WidgetGroup
+	expanded map[string]bool
+	more     *MoreWidget
+	config   *config.Config
+	events   chan render.Event
+}
+
+// RendererNew creates a GTK Renderer. GTK must be initialized
+func RendererNew() *Renderer {
+	renderer := &Renderer{
+		expanded: make(map[string]bool),
+		config:   config.Default(),
+		events:   make(chan render.Event, 10),
+	}
+	glib.IdleAdd(renderer.watchMonitors)
+	return renderer
+}
+
+// Show builds and shows the widgets for the popup, one per monitor if it is mirrored
+func (renderer *Renderer) Show(popup render.Popup) {
+	glib.IdleAdd(func() {
+		group := &WidgetGroup{Popup: popup}
+		if err := renderer.resize(group, monitorCount()); err != nil {
+			fmt.Println("Error building widget", err)
+			group.Close()
+			renderer.events <- render.Event{Type: render.Failed, ID: popup.Notification.ID}
+			return
+		}
+		renderer.groups = append(renderer.groups, group)
+		renderer.reflow()
+	})
+}
+
+// Update replaces the contents of the widgets showing the same notification
+func (renderer *Renderer) Update(popup render.Popup) {
+	glib.IdleAdd(func() {
+		group := renderer.get(popup.Notification.ID)
+		if group == nil {
+			return
+		}
+		group.Update(popup)
+		if err := renderer.resize(group, monitorCount()); err != nil {
+			fmt.Println("Error building widget", err)
+		}
+		renderer.reflow()
+	})
+}
+
+// Close destroys the widgets showing the notification
+func (renderer *Renderer) Close(id uint32) {
+	glib.IdleAdd(func() {
+		if group := renderer.remove(id); group != nil {
+			group.Close()
+			renderer.reflow()
+		}
+	})
+}
+
+// Events returns the channel on which clicks on the widgets are delivered
+func (renderer *Renderer) Events() <-chan render.Event {
+	return renderer.events
+}
+
+// SetQueued shows how many notifications are waiting above the popups, or hides the indicator if there are none
+func (renderer *Renderer) SetQueued(count int) {
+	glib.IdleAdd(func() {
+		if count == 0 {
+			if renderer.more != nil {
+				renderer.more.Close()
+				renderer.more = nil
+				renderer.reflow()
+			}
+			return
+		}
+
+		if renderer.more == nil {
+			more, err := MoreWidgetNew()
+			if err != nil {
+				fmt.Println("Error building the queue indicator", err)
+				return
+			}
+			more.Window.Connect("size-allocate", renderer.reflow)
+			renderer.more = more
+		}
+		renderer.more.Update(count)
+	})
+}
+
+// Configure applies the configuration to the widgets created from now on, and repacks the visible ones
+func (renderer *Renderer) Configure(configuration *config.Config) {
+	glib.IdleAdd(func() {
+		renderer.config = configuration
+		renderer.reflow()
+	})
+}
+
+// Quit stops the GTK main loop
+func (renderer *Renderer) Quit() {
+	glib.IdleAdd(gtk.MainQuit)
+}
+
+func (renderer *Renderer) get(id uint32) *WidgetGroup {
+	for _, group := range renderer.groups {
+		if group.Popup.Notification.ID == id {
+			return group
+		}
+	}
+	return nil
+}
+
+func (renderer *Renderer) remove(id uint32) *WidgetGroup {
+	filtered := renderer.groups[:0]
+	var removed *WidgetGroup
+	for _, group := range renderer.groups {
+		if group.Popup.Notification.ID != id {
+			filtered = append(filtered, group)
+		} else {
+			removed = group
+		}
+	}
+	renderer.groups = filtered
+	return removed
+}
+
+// resize creates or destroys copies so that the group has one widget per monitor, or a single one if it is not mirrored
+func (renderer *Renderer) resize(group *WidgetGroup, monitors int) error {
+	copies := group.copies(monitors)
+	for len(group.Widgets) > copies {
+		last := len(group.Widgets) - 1
+		group.Widgets[last].Close()
+		group.Widgets = group.Widgets[:last]
+	}
+	for len(group.Widgets) < copies {
+		widget, err := NotificationWidgetNew(group.Popup, renderer.config, renderer.events)
+		if err != nil {
+			return err
+		}
+		widget.Window.Connect("size-allocate", renderer.reflow)
+		widget.StackToggle.Connect("clicked", func() { renderer.toggle(group.Popup.Group) })
+		widget.StackDismiss.Connect("clicked", func() { renderer.dismiss(group.Popup.Group) })
+		group.Widgets = append(group.Widgets, widget)
+		widget.Show()
+	}
+	return nil
+}
+
+// monitorCount returns the number of monitors, or 1 if they cannot be listed
+func monitorCount() int {
+	outputs, err := getOutputs()
+	if err != nil || len(outputs) == 0 {
+		return 1
+	}
+	return len(outputs)
+}
@@ -0,0 +1,65 @@
+package ui
+
+import (
+	"github.com/ahirata/notifyme/internal/pkg/render"
+)
+
+// arrange returns the groups to display, oldest first. The popups of a stack are gathered at the place of the
+// newest one and collapsed into it, unless the stack was expanded, in which case they are all listed there
+func (renderer *Renderer) arrange() []*WidgetGroup {
+	stacks := make(map[string][]*WidgetGroup)
+	for _, group := range renderer.groups {
+		if key := group.Popup.Group; key != "" {
+			stacks[key] = append(stacks[key], group)
+		}
+	}
+	for key := range renderer.expanded {
+		if len(stacks[key]) < 2 {
+			delete(renderer.expanded, key)
+		}
+	}
+
+	var arranged []*WidgetGroup
+	for _, group := range renderer.groups {
+		stack := stacks[group.Popup.Group]
+		if len(stack) < 2 {
+			group.setStack(0, false)
+			group.setHidden(false)
+			arranged = append(arranged, group)
+			continue
+		}
+		newest := stack[len(stack)-1]
+		if group != newest {
+			continue
+		}
+		expanded := renderer.expanded[group.Popup.Group]
+		for _, member := range stack {
+			if member == newest {
+				member.setStack(len(stack), expanded)
+			} else {
+				member.setStack(0, false)
+			}
+			member.setHidden(!expanded && member != newest)
+			if expanded || member == newest {
+				arranged = append(arranged, member)
+			}
+		}
+	}
+	return arranged
+}
+
+// toggle expands or collapses the stack
+func (renderer *Renderer) toggle(key string) {
+	renderer.expanded[key] = !renderer.expanded[key]
+	renderer.reflow()
+}
+
+// dismiss dismisses every popup of the stack, each one reported on its own so that every notification
+// gets its own NotificationClosed
+func (renderer *Renderer) dismiss(key string) {
+	for _, group := range renderer.groups {
+		if group.Popup.Group == key {
+			renderer.events <- render.Event{Type: render.Dismissed, ID: group.Popup.Notification.ID}
+		}
+	}
+}
@@ -63,6 +63,15 @@ func AddClass(container StyledContainer, class string) {
 	style.Save()
 }
 
+// RemoveClass ...
+func RemoveClass(container StyledContainer, class string) {
+	style, err := container.GetStyleContext()
+	if err != nil {
+		return
+	}
+	style.RemoveClass(class)
+}
+
 // AddBox ...
 func AddBox(container Container, orientation gtk.Orientation, class string) (*gtk.Box, error) {
 	box, err := gtk.BoxNew(orientation, 0)
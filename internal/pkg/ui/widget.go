@@ -1,6 +1,9 @@
 package ui
 
 import (
+	"fmt"
+	"github.com/ahirata/notifyme/internal/pkg/config"
+	"github.com/ahirata/notifyme/internal/pkg/render"
 	"github.com/ahirata/notifyme/pkg/notifyme/schema"
 	"github.com/gotk3/gotk3/gdk"
 	"github.com/gotk3/gotk3/gtk"
@@ -8,27 +11,32 @@ import (
 	"strings"
 )
 
-var (
-	defaultOffsetX = 10
-	defaultOffsetY = 10
-)
-
 // NotificationWidget ...
 type NotificationWidget struct {
 	Notification *schema.Notification
 	Window       *gtk.Window
 	Icon         *gtk.Image
 	Summary      *gtk.Label
+	Repeats      *gtk.Label
 	Body         *gtk.Label
 	Actions      map[string]*gtk.Button
 	Buttons      []*gtk.Button
-	channel      chan schema.ActionInvoked
+	Stack        *gtk.Box
+	StackLabel   *gtk.Label
+	StackToggle  *gtk.Button
+	StackDismiss *gtk.Button
+	stackCount   int
+	expanded     bool
+	classes      []string
+	config       *config.Config
+	channel      chan render.Event
 }
 
 // NotificationWidgetNew ...
-func NotificationWidgetNew(notification *schema.Notification, maxY int, channel chan schema.ActionInvoked) (*NotificationWidget, error) {
+func NotificationWidgetNew(popup render.Popup, configuration *config.Config, channel chan render.Event) (*NotificationWidget, error) {
 	var err error
-	widget := NotificationWidget{Notification: notification, channel: channel}
+	notification := popup.Notification
+	widget := NotificationWidget{Notification: notification, config: configuration, channel: channel}
 	if widget.Window, err = gtk.WindowNew(gtk.WINDOW_POPUP); err != nil {
 		return nil, err
 	}
@@ -38,33 +46,49 @@ func NotificationWidgetNew(notification *schema.Notification, maxY int, channel
 	if widget.Body, err = gtk.LabelNew(notification.Body); err != nil {
 		return nil, err
 	}
+	if widget.Repeats, err = gtk.LabelNew(""); err != nil {
+		return nil, err
+	}
 	if widget.Icon, err = gtk.ImageNew(); err != nil {
 		return nil, err
 	}
-	if widget.Buttons, err = widget.createButtons(notification); err != nil {
+	if widget.StackLabel, err = gtk.LabelNew(""); err != nil {
 		return nil, err
 	}
-	if err = widget.configure(); err != nil {
+	if widget.StackToggle, err = gtk.ButtonNewWithLabel(""); err != nil {
+		return nil, err
+	}
+	if widget.StackDismiss, err = gtk.ButtonNewWithLabel("Dismiss group"); err != nil {
+		return nil, err
+	}
+	if widget.Buttons, err = widget.createButtons(notification); err != nil {
 		return nil, err
 	}
-	if err = widget.place(maxY); err != nil {
+	if err = widget.configure(); err != nil {
 		return nil, err
 	}
+	widget.trackPointer()
+	widget.setClasses(popupClasses(popup))
+	widget.setRepeats(popup.Repeats)
 	return &widget, nil
 }
 
 func (widget *NotificationWidget) createButtons(notification *schema.Notification) ([]*gtk.Button, error) {
+	actions, err := notification.ActionPairs()
+	if err != nil {
+		return nil, err
+	}
+
 	var buttons []*gtk.Button
-	for i, j := 0, 1; j < len(notification.Actions); i, j = i+2, j+2 {
-		actionID := notification.Actions[i].(string)
-		actionName := notification.Actions[j].(string)
+	for _, action := range actions {
+		actionID := action.Key
 
-		button, err := gtk.ButtonNewWithLabel(actionName)
+		button, err := gtk.ButtonNewWithLabel(action.Label)
 		if err != nil {
 			return nil, err
 		}
 		button.Connect("button-release-event", func() {
-			widget.CloseAction(actionID)
+			widget.InvokeAction(actionID)
 		})
 		buttons = append(buttons, button)
 	}
@@ -73,9 +97,9 @@ func (widget *NotificationWidget) createButtons(notification *schema.Notificatio
 
 func (widget *NotificationWidget) configure() error {
 	configureWindow(widget.Window)
-	configureSummary(widget.Summary)
-	configureBody(widget.Body)
-	setIcon(widget.Icon, widget.Notification)
+	configureSummary(widget.Summary, widget.config.MaxWidthChars)
+	configureBody(widget.Body, widget.config.MaxWidthChars)
+	setIcon(widget.Icon, widget.Notification, widget.config.IconSize)
 
 	return widget.layout()
 }
@@ -86,42 +110,44 @@ func configureWindow(window *gtk.Window) {
 	window.SetSkipPagerHint(true)
 	window.SetDecorated(false)
 	window.SetTypeHint(gdk.WINDOW_TYPE_HINT_NOTIFICATION)
-	window.SetGravity(gdk.GDK_GRAVITY_SOUTH_EAST)
+	// the layout positions the windows from their top left corner, whatever the anchor
+	window.SetGravity(gdk.GDK_GRAVITY_NORTH_WEST)
 	window.SetCanFocus(false)
 	window.SetAcceptFocus(false)
 	window.SetKeepAbove(true)
 }
 
-func configureSummary(label *gtk.Label) {
+func configureSummary(label *gtk.Label, maxWidthChars int) {
 	label.SetUseMarkup(true)
 	label.SetLineWrap(false)
 	label.SetHAlign(gtk.ALIGN_START)
 	label.SetXAlign(0)
-	label.SetMaxWidthChars(45)
+	label.SetMaxWidthChars(maxWidthChars)
 	label.SetEllipsize(pango.ELLIPSIZE_END)
 }
 
-func configureBody(label *gtk.Label) {
+func configureBody(label *gtk.Label, maxWidthChars int) {
 	label.SetUseMarkup(true)
 	label.SetLineWrap(false)
 	label.SetHAlign(gtk.ALIGN_START)
 	label.SetXAlign(0)
-	label.SetMaxWidthChars(45)
+	label.SetMaxWidthChars(maxWidthChars)
 	label.SetEllipsize(pango.ELLIPSIZE_END)
 }
 
-func setIcon(icon *gtk.Image, notification *schema.Notification) {
+func setIcon(icon *gtk.Image, notification *schema.Notification, size int) {
 	icon.Clear()
 	if imageData, found := notification.ImageData(); found {
-		icon.SetFromPixbuf(pixbufNewFromImageData(&imageData))
+		icon.SetFromPixbuf(pixbufNewFromImageData(&imageData, size))
 	} else if imagePath, found := notification.ImagePath(); found {
-		icon.SetFromPixbuf(loadPixbufFromFile(imagePath, 64, 64))
+		icon.SetFromPixbuf(loadPixbufFromFile(imagePath, size, size))
 	} else if strings.HasPrefix(notification.AppIcon, "file://") {
-		icon.SetFromPixbuf(loadPixbufFromFile(notification.AppIcon, 64, 64))
+		icon.SetFromPixbuf(loadPixbufFromFile(notification.AppIcon, size, size))
 	} else if notification.AppIcon != "" {
 		icon.SetFromIconName(notification.AppIcon, gtk.ICON_SIZE_DIALOG)
-	} else if iconData, found := notification.IconData(); found {
-		icon.SetFromPixbuf(pixbufNewFromImageData(&iconData))
+		icon.SetPixelSize(size)
+	} else if iconData, found := notification.LegacyIconData(); found {
+		icon.SetFromPixbuf(pixbufNewFromImageData(&iconData, size))
 	}
 	return
 }
@@ -130,8 +156,8 @@ func loadImageFromFile(filename string, width, height int) (*gtk.Image, error) {
 	return gtk.ImageNewFromPixbuf(loadPixbufFromFile(filename, width, height))
 }
 
-func pixbufNewFromImageData(imageData *schema.ImageData) *gdk.Pixbuf {
-	pixbuf, err := pixbufNewFromData(imageData.Data, gdk.COLORSPACE_RGB, imageData.HasAlpha, int(imageData.BitsPerSample), int(imageData.Width), int(imageData.Height), 64, 64)
+func pixbufNewFromImageData(imageData *schema.ImageData, size int) *gdk.Pixbuf {
+	pixbuf, err := pixbufNewFromData(imageData.Data, gdk.COLORSPACE_RGB, imageData.HasAlpha, int(imageData.BitsPerSample), int(imageData.RowStride), int(imageData.Width), int(imageData.Height), size, size)
 	if err != nil {
 		return nil
 	}
@@ -145,12 +171,25 @@ func (widget *NotificationWidget) layout() error {
 	AddClass(widget.Window, "notifyme")
 	AddClass(widget.Summary, "summary")
 	AddClass(widget.Body, "body")
+	AddClass(widget.Repeats, "repeats")
 
 	vbox, err := AddBox(widget.Window, gtk.ORIENTATION_VERTICAL, "main")
 	if err != nil {
 		return err
 	}
 
+	// the stack bar is only shown on the newest popup of a stack, see setStack
+	if widget.Stack, err = AddBox(vbox, gtk.ORIENTATION_HORIZONTAL, "stack"); err != nil {
+		return err
+	}
+	widget.Stack.Add(widget.StackLabel)
+	widget.Stack.PackEnd(widget.StackDismiss, false, false, 0)
+	widget.Stack.PackEnd(widget.StackToggle, false, false, 0)
+	widget.StackLabel.Show()
+	widget.StackToggle.Show()
+	widget.StackDismiss.Show()
+	widget.Stack.SetNoShowAll(true)
+
 	content, err := AddBox(vbox, gtk.ORIENTATION_HORIZONTAL, "content")
 	if err != nil {
 		return (err)
@@ -161,7 +200,13 @@ func (widget *NotificationWidget) layout() error {
 	if err != nil {
 		return err
 	}
-	textBox.Add(widget.Summary)
+	header, err := AddBox(textBox, gtk.ORIENTATION_HORIZONTAL, "header")
+	if err != nil {
+		return err
+	}
+	header.Add(widget.Summary)
+	header.PackEnd(widget.Repeats, false, false, 0)
+	widget.Repeats.SetNoShowAll(true)
 	textBox.Add(widget.Body)
 
 	actions, err := AddBox(vbox, gtk.ORIENTATION_HORIZONTAL, "actions")
@@ -177,53 +222,86 @@ func (widget *NotificationWidget) layout() error {
 	return nil
 }
 
-func (widget *NotificationWidget) place(maxY int) error {
-	workarea, err := getWorkarea(widget.Window)
-	if err != nil {
-		panic(err)
-	}
-
-	widget.Window.Connect("size-allocate", func() {
-		positionX := widget.getPositionX(workarea)
-		positionY := widget.getPositionY(workarea, maxY)
-
-		widget.Window.Move(positionX, positionY)
-	})
-
-	return nil
+// popupClasses returns the style classes of the window: the urgency and the ones added by the rules
+func popupClasses(popup render.Popup) []string {
+	return append([]string{popup.Notification.Urgency.String()}, popup.Classes...)
 }
 
-func (widget *NotificationWidget) getPositionX(workarea *gdk.Rectangle) int {
-	width := widget.Window.GetAllocatedWidth()
-	return workarea.GetX() + workarea.GetWidth() - width - defaultOffsetX
-}
-
-func (widget *NotificationWidget) getPositionY(workarea *gdk.Rectangle, maxY int) int {
-	height := widget.Window.GetAllocatedHeight()
-	positionY := workarea.GetY() + workarea.GetHeight()
-	if maxY < positionY {
-		positionY = maxY
+func (widget *NotificationWidget) setClasses(classes []string) {
+	for _, class := range widget.classes {
+		RemoveClass(widget.Window, class)
+	}
+	for _, class := range classes {
+		AddClass(widget.Window, class)
 	}
-	return positionY - height - defaultOffsetY
+	widget.classes = classes
 }
 
-// ReplaceNotification replaces the image, summary and body of the notification with same ID
-func (widget *NotificationWidget) ReplaceNotification(notification *schema.Notification) {
-	setIcon(widget.Icon, notification)
+// ReplaceNotification replaces the image, summary, body and style of the popup with same ID
+func (widget *NotificationWidget) ReplaceNotification(popup render.Popup) {
+	notification := popup.Notification
+	setIcon(widget.Icon, notification, widget.config.IconSize)
 	widget.Summary.SetLabel(notification.Summary)
 	widget.Body.SetLabel(notification.Body)
+	widget.setClasses(popupClasses(popup))
+	widget.setRepeats(popup.Repeats)
 	widget.Notification = notification
 }
 
+// setRepeats shows a "×N" badge when the popup stands for several identical notifications
+func (widget *NotificationWidget) setRepeats(repeats int) {
+	if repeats <= 1 {
+		widget.Repeats.Hide()
+		return
+	}
+	widget.Repeats.SetText(fmt.Sprintf("×%d", repeats))
+	widget.Repeats.Show()
+}
+
+// setStack shows how many popups are collapsed into this one, or hides the stack bar if there are none.
+// It is a no-op when nothing changed, since it runs on every reflow
+func (widget *NotificationWidget) setStack(count int, expanded bool) {
+	if count == widget.stackCount && expanded == widget.expanded {
+		return
+	}
+	widget.stackCount = count
+	widget.expanded = expanded
+	if count <= 1 {
+		widget.Stack.Hide()
+		return
+	}
+	widget.StackLabel.SetText(fmt.Sprintf("%d notifications from %s", count, widget.Notification.AppName))
+	if expanded {
+		widget.StackToggle.SetLabel("Show less")
+	} else {
+		widget.StackToggle.SetLabel("Show all")
+	}
+	widget.Stack.Show()
+}
+
 // Close closes the widget
 func (widget *NotificationWidget) Close() {
 	widget.Window.Destroy()
 }
 
-// CloseAction ...
-func (widget *NotificationWidget) CloseAction(actionKey string) {
-	widget.channel <- schema.ActionInvoked{ID: widget.Notification.ID, ActionKey: actionKey}
-	widget.Close()
+// InvokeAction reports that the user clicked on an action
+func (widget *NotificationWidget) InvokeAction(actionKey string) {
+	widget.channel <- render.Event{Type: render.ActionInvoked, ID: widget.Notification.ID, ActionKey: actionKey}
+}
+
+// trackPointer reports the pointer moving over and out of the window, ignoring the moves between its children
+func (widget *NotificationWidget) trackPointer() {
+	widget.Window.AddEvents(int(gdk.ENTER_NOTIFY_MASK | gdk.LEAVE_NOTIFY_MASK))
+	crossing := func(eventType render.EventType) func(*gtk.Window, *gdk.Event) bool {
+		return func(window *gtk.Window, event *gdk.Event) bool {
+			if gdk.EventCrossingNewFromEvent(event).Detail() != gdk.NOTIFY_INFERIOR {
+				widget.channel <- render.Event{Type: eventType, ID: widget.Notification.ID}
+			}
+			return false
+		}
+	}
+	widget.Window.Connect("enter-notify-event", crossing(render.PointerEntered))
+	widget.Window.Connect("leave-notify-event", crossing(render.PointerLeft))
 }
 
 // Show shows the widget
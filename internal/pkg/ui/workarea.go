@@ -3,17 +3,44 @@ package ui
 // #cgo pkg-config: gdk-3.0 gtk+-3.0
 // #include <gtk/gtk.h>
 // #include <gdk/gdk.h>
+//
+// static GdkMonitor *pointer_monitor(GdkDisplay *display) {
+// 	GdkSeat *seat = gdk_display_get_default_seat(display);
+// 	if (seat == NULL) {
+// 		return NULL;
+// 	}
+// 	GdkDevice *pointer = gdk_seat_get_pointer(seat);
+// 	if (pointer == NULL) {
+// 		return NULL;
+// 	}
+// 	gint x, y;
+// 	gdk_device_get_position(pointer, NULL, &x, &y);
+// 	return gdk_display_get_monitor_at_point(display, x, y);
+// }
+//
+// static GdkMonitor *focused_monitor(GdkDisplay *display, GdkScreen *screen) {
+// 	G_GNUC_BEGIN_IGNORE_DEPRECATIONS
+// 	GdkWindow *active = gdk_screen_get_active_window(screen);
+// 	G_GNUC_END_IGNORE_DEPRECATIONS
+// 	if (active == NULL) {
+// 		return NULL;
+// 	}
+// 	GdkMonitor *monitor = gdk_display_get_monitor_at_window(display, active);
+// 	g_object_unref(active);
+// 	return monitor;
+// }
 import "C"
 
 import (
-	"errors"
+	"github.com/ahirata/notifyme/internal/pkg/layout"
 	"github.com/gotk3/gotk3/gdk"
-	"github.com/gotk3/gotk3/gtk"
 	"unsafe"
 )
 
-func getWorkarea(window *gtk.Window) (*gdk.Rectangle, error) {
-	screen, err := window.GetScreen()
+// getOutputs lists the monitors of the default display. They are looked up on every call
+// so that the popups follow hot-plugged monitors
+func getOutputs() ([]layout.Output, error) {
+	screen, err := gdk.ScreenGetDefault()
 	if err != nil {
 		return nil, err
 	}
@@ -23,13 +50,40 @@ func getWorkarea(window *gtk.Window) (*gdk.Rectangle, error) {
 		return nil, err
 	}
 
-	monitor := C.gdk_display_get_primary_monitor((*C.GdkDisplay)(unsafe.Pointer(display.GObject)))
-	if monitor == nil {
-		return nil, errors.New("cgo returned unexpected nil pointer")
+	gdkDisplay := (*C.GdkDisplay)(unsafe.Pointer(display.GObject))
+	gdkScreen := (*C.GdkScreen)(unsafe.Pointer(screen.GObject))
+	pointer := C.pointer_monitor(gdkDisplay)
+	focused := C.focused_monitor(gdkDisplay, gdkScreen)
+
+	var outputs []layout.Output
+	for i := 0; i < int(C.gdk_display_get_n_monitors(gdkDisplay)); i++ {
+		monitor := C.gdk_display_get_monitor(gdkDisplay, C.int(i))
+		if monitor == nil {
+			continue
+		}
+
+		gdkRectangle := C.GdkRectangle{}
+		C.gdk_monitor_get_workarea(monitor, &gdkRectangle)
+		outputs = append(outputs, layout.Output{
+			Manufacturer: goString(C.gdk_monitor_get_manufacturer(monitor)),
+			Model:        goString(C.gdk_monitor_get_model(monitor)),
+			Workarea: layout.Rectangle{
+				X:      int(gdkRectangle.x),
+				Y:      int(gdkRectangle.y),
+				Width:  int(gdkRectangle.width),
+				Height: int(gdkRectangle.height),
+			},
+			Primary: C.gdk_monitor_is_primary(monitor) != 0,
+			Pointer: monitor == pointer,
+			Focused: monitor == focused,
+		})
 	}
+	return outputs, nil
+}
 
-	gdkRectangle := C.GdkRectangle{}
-	C.gdk_monitor_get_workarea(monitor, &gdkRectangle)
-	workarea := gdk.WrapRectangle(uintptr(unsafe.Pointer(&gdkRectangle)))
-	return workarea, nil
+func goString(value *C.char) string {
+	if value == nil {
+		return ""
+	}
+	return C.GoString(value)
 }
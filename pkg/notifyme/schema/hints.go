@@ -0,0 +1,301 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/godbus/dbus"
+)
+
+// Hint keys defined by the specification. The deprecated names are still
+// accepted as fallbacks of their current counterparts
+const (
+	HintActionIcons   = "action-icons"
+	HintCategory      = "category"
+	HintDesktopEntry  = "desktop-entry"
+	HintImageData     = "image-data"
+	HintImagePath     = "image-path"
+	HintResident      = "resident"
+	HintSoundFile     = "sound-file"
+	HintSoundName     = "sound-name"
+	HintSuppressSound = "suppress-sound"
+	HintTransient     = "transient"
+	HintUrgency       = "urgency"
+	HintValue         = "value"
+	HintX             = "x"
+	HintY             = "y"
+
+	// Deprecated since version 1.1 of the specification
+	HintImageDataDeprecated = "image_data"
+	HintImagePathDeprecated = "image_path"
+	HintIconDataDeprecated  = "icon_data"
+
+	// Deprecated: not a hint of the specification, which names it icon_data. Use HintIconDataDeprecated
+	HintIconData = "icon-data"
+)
+
+// Hints holds the hints sent along with a notification
+type Hints map[string]dbus.Variant
+
+// HintError is returned when a hint is present but its value is not valid
+type HintError struct {
+	Key    string
+	Value  dbus.Variant
+	Reason string
+}
+
+func (err *HintError) Error() string {
+	return fmt.Sprintf("invalid hint %q (%s): %s", err.Key, err.Value.Signature(), err.Reason)
+}
+
+// Urgency returns the urgency level of the notification
+func (hints Hints) Urgency() (Urgency, bool, error) {
+	value, found, err := hints.integer(HintUrgency)
+	if !found || err != nil {
+		return Normal, found, err
+	}
+	if value < int64(Low) || value > int64(Critical) {
+		return Normal, true, hints.invalid(HintUrgency, "out of range")
+	}
+	return Urgency(value), true, nil
+}
+
+// Category returns the type of notification this is
+func (hints Hints) Category() (string, bool, error) {
+	return hints.text(HintCategory)
+}
+
+// DesktopEntry returns the name of the desktop file of the calling program
+func (hints Hints) DesktopEntry() (string, bool, error) {
+	return hints.text(HintDesktopEntry)
+}
+
+// ImageData returns the raw image, falling back to the deprecated name
+func (hints Hints) ImageData() (ImageData, bool, error) {
+	return hints.image(HintImageData, HintImageDataDeprecated)
+}
+
+// LegacyIconData returns the raw icon of the deprecated icon_data hint, which the specification ranks below
+// image-data, image-path and the app icon
+func (hints Hints) LegacyIconData() (ImageData, bool, error) {
+	return hints.image(HintIconDataDeprecated)
+}
+
+// IconData returns the raw icon of the deprecated icon_data hint.
+//
+// Deprecated: use LegacyIconData, only when there is no image-data, image-path nor app icon
+func (hints Hints) IconData() (ImageData, bool, error) {
+	return hints.LegacyIconData()
+}
+
+// ImagePath returns the path or uri of the image, falling back to the deprecated name
+func (hints Hints) ImagePath() (string, bool, error) {
+	if _, found := hints[HintImagePath]; found {
+		return hints.text(HintImagePath)
+	}
+	return hints.text(HintImagePathDeprecated)
+}
+
+// Transient returns whether the notification should bypass the server's persistence
+func (hints Hints) Transient() (bool, bool, error) {
+	return hints.boolean(HintTransient)
+}
+
+// Resident returns whether the notification should stay after an action is invoked
+func (hints Hints) Resident() (bool, bool, error) {
+	return hints.boolean(HintResident)
+}
+
+// ActionIcons returns whether the action keys should be interpreted as icon names
+func (hints Hints) ActionIcons() (bool, bool, error) {
+	return hints.boolean(HintActionIcons)
+}
+
+// SuppressSound returns whether the server should not play any sound
+func (hints Hints) SuppressSound() (bool, bool, error) {
+	return hints.boolean(HintSuppressSound)
+}
+
+// SoundFile returns the path to a sound file to play
+func (hints Hints) SoundFile() (string, bool, error) {
+	return hints.text(HintSoundFile)
+}
+
+// SoundName returns a themeable named sound to play
+func (hints Hints) SoundName() (string, bool, error) {
+	return hints.text(HintSoundName)
+}
+
+// X returns the x location on the screen the notification should point to
+func (hints Hints) X() (int32, bool, error) {
+	return hints.int32(HintX)
+}
+
+// Y returns the y location on the screen the notification should point to
+func (hints Hints) Y() (int32, bool, error) {
+	return hints.int32(HintY)
+}
+
+// Value returns the progress value, usually between 0 and 100
+func (hints Hints) Value() (int32, bool, error) {
+	return hints.int32(HintValue)
+}
+
+// Validate checks every known hint and returns the first error found
+func (hints Hints) Validate() error {
+	checks := []func() error{
+		func() error { _, _, err := hints.Urgency(); return err },
+		func() error { _, _, err := hints.Category(); return err },
+		func() error { _, _, err := hints.DesktopEntry(); return err },
+		func() error { _, _, err := hints.ImageData(); return err },
+		func() error { _, _, err := hints.LegacyIconData(); return err },
+		func() error { _, _, err := hints.ImagePath(); return err },
+		func() error { _, _, err := hints.Transient(); return err },
+		func() error { _, _, err := hints.Resident(); return err },
+		func() error { _, _, err := hints.ActionIcons(); return err },
+		func() error { _, _, err := hints.SuppressSound(); return err },
+		func() error { _, _, err := hints.SoundFile(); return err },
+		func() error { _, _, err := hints.SoundName(); return err },
+		func() error { _, _, err := hints.X(); return err },
+		func() error { _, _, err := hints.Y(); return err },
+		func() error { _, _, err := hints.Value(); return err },
+	}
+	for _, check := range checks {
+		if err := check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (hints Hints) invalid(key string, reason string) error {
+	return &HintError{Key: key, Value: hints[key], Reason: reason}
+}
+
+func (hints Hints) text(key string) (string, bool, error) {
+	variant, found := hints[key]
+	if !found {
+		return "", false, nil
+	}
+	value, ok := variant.Value().(string)
+	if !ok {
+		return "", true, hints.invalid(key, "expected a string")
+	}
+	return value, true, nil
+}
+
+// boolean also accepts integers, as sent by tools such as notify-send
+func (hints Hints) boolean(key string) (bool, bool, error) {
+	variant, found := hints[key]
+	if !found {
+		return false, false, nil
+	}
+	if value, ok := variant.Value().(bool); ok {
+		return value, true, nil
+	}
+	value, _, err := hints.integer(key)
+	if err != nil {
+		return false, true, hints.invalid(key, "expected a boolean")
+	}
+	return value != 0, true, nil
+}
+
+func (hints Hints) int32(key string) (int32, bool, error) {
+	value, found, err := hints.integer(key)
+	if !found || err != nil {
+		return 0, found, err
+	}
+	if value < math.MinInt32 || value > math.MaxInt32 {
+		return 0, true, hints.invalid(key, "out of range")
+	}
+	return int32(value), true, nil
+}
+
+// integer accepts any of the integer types, since clients disagree on them
+func (hints Hints) integer(key string) (int64, bool, error) {
+	variant, found := hints[key]
+	if !found {
+		return 0, false, nil
+	}
+	switch value := variant.Value().(type) {
+	case byte:
+		return int64(value), true, nil
+	case int16:
+		return int64(value), true, nil
+	case uint16:
+		return int64(value), true, nil
+	case int32:
+		return int64(value), true, nil
+	case uint32:
+		return int64(value), true, nil
+	case int64:
+		return value, true, nil
+	case uint64:
+		if value > math.MaxInt64 {
+			return 0, true, hints.invalid(key, "out of range")
+		}
+		return int64(value), true, nil
+	}
+	return 0, true, hints.invalid(key, "expected an integer")
+}
+
+func (hints Hints) image(keys ...string) (ImageData, bool, error) {
+	for _, key := range keys {
+		if _, found := hints[key]; found {
+			return hints.imageData(key)
+		}
+	}
+	return ImageData{}, false, nil
+}
+
+func (hints Hints) imageData(key string) (ImageData, bool, error) {
+	fields, ok := hints[key].Value().([]interface{})
+	if !ok || len(fields) != 7 {
+		return ImageData{}, true, hints.invalid(key, "expected a (iiibiiay) struct")
+	}
+
+	var image ImageData
+	var valid [7]bool
+	image.Width, valid[0] = fields[0].(int32)
+	image.Height, valid[1] = fields[1].(int32)
+	image.RowStride, valid[2] = fields[2].(int32)
+	image.HasAlpha, valid[3] = fields[3].(bool)
+	image.BitsPerSample, valid[4] = fields[4].(int32)
+	image.Channels, valid[5] = fields[5].(int32)
+	image.Data, valid[6] = fields[6].([]byte)
+	for _, ok := range valid {
+		if !ok {
+			return ImageData{}, true, hints.invalid(key, "expected a (iiibiiay) struct")
+		}
+	}
+
+	if err := image.Validate(); err != nil {
+		return ImageData{}, true, hints.invalid(key, err.Error())
+	}
+	return image, true, nil
+}
+
+// Validate checks that the image dimensions are consistent with its data
+func (image *ImageData) Validate() error {
+	if image.Width <= 0 || image.Height <= 0 {
+		return fmt.Errorf("invalid dimensions %dx%d", image.Width, image.Height)
+	}
+	if image.BitsPerSample != 8 {
+		return fmt.Errorf("unsupported bits per sample %d", image.BitsPerSample)
+	}
+	channels := int32(3)
+	if image.HasAlpha {
+		channels = 4
+	}
+	if image.Channels != channels {
+		return fmt.Errorf("unsupported channels %d with alpha %t", image.Channels, image.HasAlpha)
+	}
+	rowLength := int64(image.Width) * int64(image.Channels)
+	if int64(image.RowStride) < rowLength {
+		return fmt.Errorf("row stride %d shorter than row length %d", image.RowStride, rowLength)
+	}
+	if expected := int64(image.RowStride)*int64(image.Height-1) + rowLength; int64(len(image.Data)) < expected {
+		return fmt.Errorf("expected at least %d bytes of data, got %d", expected, len(image.Data))
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/godbus/dbus"
+)
+
+var fuzzHintKeys = []string{
+	HintActionIcons, HintCategory, HintDesktopEntry, HintImageData, HintImagePath,
+	HintResident, HintSoundFile, HintSoundName, HintSuppressSound, HintTransient, HintUrgency,
+	HintValue, HintX, HintY, HintImageDataDeprecated, HintImagePathDeprecated, HintIconDataDeprecated,
+}
+
+// fuzzVariant builds a variant of a type selected by kind out of the raw bytes
+func fuzzVariant(kind byte, width, height, stride int32, data []byte) dbus.Variant {
+	switch kind % 10 {
+	case 0:
+		return dbus.MakeVariant(string(data))
+	case 1:
+		return dbus.MakeVariant(len(data)%2 == 0)
+	case 2:
+		return dbus.MakeVariant(byte(width))
+	case 3:
+		return dbus.MakeVariant(width)
+	case 4:
+		return dbus.MakeVariant(uint64(height))
+	case 5:
+		return dbus.MakeVariant(data)
+	case 6:
+		return dbus.MakeVariant([]interface{}{width, height, stride, len(data)%2 == 0, int32(8), int32(3 + len(data)%2), data})
+	case 7:
+		return dbus.MakeVariant([]interface{}{width, height, stride, true, int32(8), int32(4)})
+	case 8:
+		return dbus.MakeVariant([]interface{}{string(data), height, stride, false, width, int32(3), data})
+	default:
+		return dbus.MakeVariant(dbus.MakeVariant(width))
+	}
+}
+
+func FuzzHints(f *testing.F) {
+	f.Add(byte(3), byte(6), int32(2), int32(2), int32(6), []byte("0123456789ab"))
+	f.Add(byte(11), byte(2), int32(1), int32(0), int32(0), []byte{})
+	f.Add(byte(10), byte(0), int32(0), int32(0), int32(0), []byte("file:///tmp/image.png"))
+	f.Fuzz(func(t *testing.T, key byte, kind byte, width, height, stride int32, data []byte) {
+		hints := Hints{fuzzHintKeys[int(key)%len(fuzzHintKeys)]: fuzzVariant(kind, width, height, stride, data)}
+		notification := Notification{Hints: hints}
+
+		err := hints.Validate()
+		if image, found := notification.ImageData(); found {
+			if err != nil {
+				t.Fatalf("image reported found on invalid hints: %v", err)
+			}
+			if image.Validate() != nil {
+				t.Fatalf("invalid image reported as found: %+v", image)
+			}
+		}
+		if icon, found := notification.LegacyIconData(); found && (err != nil || icon.Validate() != nil) {
+			t.Fatalf("invalid icon reported as found: %+v, %v", icon, err)
+		}
+		notification.ImagePath()
+		if urgency := notification.UrgencyHint(); urgency > Critical {
+			t.Fatalf("urgency out of range: %d", urgency)
+		}
+	})
+}
+
+func FuzzActionPairs(f *testing.F) {
+	f.Add("default", "Open", 2, byte(0))
+	f.Add("key", "", 3, byte(1))
+	f.Fuzz(func(t *testing.T, key string, label string, length int, kind byte) {
+		if length < 0 || length > 16 {
+			return
+		}
+		actions := make([]interface{}, length)
+		for i := range actions {
+			switch (int(kind) + i) % 3 {
+			case 0:
+				actions[i] = key
+			case 1:
+				actions[i] = label
+			default:
+				actions[i] = int32(i)
+			}
+		}
+
+		notification := Notification{Actions: actions}
+		pairs, err := notification.ActionPairs()
+		if err != nil {
+			return
+		}
+		if len(pairs)*2 != length {
+			t.Fatalf("expected %d pairs, got %d", length/2, len(pairs))
+		}
+	})
+}
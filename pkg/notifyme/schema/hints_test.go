@@ -0,0 +1,159 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/godbus/dbus"
+)
+
+// image returns the raw variant of a valid 1x1 RGB image, filled with value
+func image(value byte) dbus.Variant {
+	return dbus.MakeVariant([]interface{}{int32(1), int32(1), int32(3), false, int32(8), int32(3), []byte{value, value, value}})
+}
+
+type hintCase struct {
+	name     string
+	hints    Hints
+	expected interface{}
+	found    bool
+	invalid  bool
+}
+
+func checkHint(t *testing.T, accessor string, cases []hintCase, get func(Hints) (interface{}, bool, error)) {
+	t.Helper()
+	for _, c := range cases {
+		value, found, err := get(c.hints)
+		if c.invalid {
+			if _, ok := err.(*HintError); !ok || !found {
+				t.Errorf("%s %s: expected a HintError, got %v (found %t)", accessor, c.name, err, found)
+			}
+			continue
+		}
+		if err != nil || found != c.found || value != c.expected {
+			t.Errorf("%s %s: expected %v (found %t), got %v (found %t, %v)", accessor, c.name, c.expected, c.found, value, found, err)
+		}
+	}
+}
+
+func TestUrgency(t *testing.T) {
+	checkHint(t, "Urgency", []hintCase{
+		{"missing", Hints{}, Normal, false, false},
+		{"byte", Hints{HintUrgency: dbus.MakeVariant(byte(2))}, Critical, true, false},
+		{"int32", Hints{HintUrgency: dbus.MakeVariant(int32(0))}, Low, true, false},
+		{"out of range", Hints{HintUrgency: dbus.MakeVariant(byte(3))}, nil, true, true},
+		{"string", Hints{HintUrgency: dbus.MakeVariant("critical")}, nil, true, true},
+	}, func(hints Hints) (interface{}, bool, error) { return hints.Urgency() })
+}
+
+func TestTextHints(t *testing.T) {
+	accessors := map[string]func(Hints) (string, bool, error){
+		HintCategory:     Hints.Category,
+		HintDesktopEntry: Hints.DesktopEntry,
+		HintSoundFile:    Hints.SoundFile,
+		HintSoundName:    Hints.SoundName,
+	}
+	for key, accessor := range accessors {
+		accessor := accessor
+		checkHint(t, key, []hintCase{
+			{"missing", Hints{}, "", false, false},
+			{"string", Hints{key: dbus.MakeVariant("value")}, "value", true, false},
+			{"number", Hints{key: dbus.MakeVariant(int32(1))}, nil, true, true},
+		}, func(hints Hints) (interface{}, bool, error) { return accessor(hints) })
+	}
+}
+
+func TestBooleanHints(t *testing.T) {
+	accessors := map[string]func(Hints) (bool, bool, error){
+		HintTransient:     Hints.Transient,
+		HintResident:      Hints.Resident,
+		HintActionIcons:   Hints.ActionIcons,
+		HintSuppressSound: Hints.SuppressSound,
+	}
+	for key, accessor := range accessors {
+		accessor := accessor
+		checkHint(t, key, []hintCase{
+			{"missing", Hints{}, false, false, false},
+			{"boolean", Hints{key: dbus.MakeVariant(true)}, true, true, false},
+			{"integer", Hints{key: dbus.MakeVariant(int32(1))}, true, true, false},
+			{"zero", Hints{key: dbus.MakeVariant(byte(0))}, false, true, false},
+			{"string", Hints{key: dbus.MakeVariant("true")}, nil, true, true},
+		}, func(hints Hints) (interface{}, bool, error) { return accessor(hints) })
+	}
+}
+
+func TestInt32Hints(t *testing.T) {
+	accessors := map[string]func(Hints) (int32, bool, error){
+		HintX:     Hints.X,
+		HintY:     Hints.Y,
+		HintValue: Hints.Value,
+	}
+	for key, accessor := range accessors {
+		accessor := accessor
+		checkHint(t, key, []hintCase{
+			{"missing", Hints{}, int32(0), false, false},
+			{"int32", Hints{key: dbus.MakeVariant(int32(-5))}, int32(-5), true, false},
+			{"uint16", Hints{key: dbus.MakeVariant(uint16(50))}, int32(50), true, false},
+			{"out of range", Hints{key: dbus.MakeVariant(int64(1) << 40)}, nil, true, true},
+			{"string", Hints{key: dbus.MakeVariant("5")}, nil, true, true},
+		}, func(hints Hints) (interface{}, bool, error) { return accessor(hints) })
+	}
+}
+
+func TestImagePath(t *testing.T) {
+	checkHint(t, "ImagePath", []hintCase{
+		{"missing", Hints{}, "", false, false},
+		{"current", Hints{HintImagePath: dbus.MakeVariant("/current.png")}, "/current.png", true, false},
+		{"deprecated", Hints{HintImagePathDeprecated: dbus.MakeVariant("/deprecated.png")}, "/deprecated.png", true, false},
+		{"both", Hints{HintImagePath: dbus.MakeVariant("/current.png"), HintImagePathDeprecated: dbus.MakeVariant("/deprecated.png")}, "/current.png", true, false},
+		{"number", Hints{HintImagePath: dbus.MakeVariant(int32(1))}, nil, true, true},
+	}, func(hints Hints) (interface{}, bool, error) { return hints.ImagePath() })
+}
+
+func TestImageData(t *testing.T) {
+	// the first byte of the pixel tells which of the hints was read
+	checkHint(t, "ImageData", []hintCase{
+		{"missing", Hints{}, byte(0), false, false},
+		{"current", Hints{HintImageData: image(1)}, byte(1), true, false},
+		{"image_data", Hints{HintImageDataDeprecated: image(2)}, byte(2), true, false},
+		{"icon_data", Hints{HintIconDataDeprecated: image(3)}, byte(0), false, false},
+		{"all", Hints{HintImageData: image(1), HintImageDataDeprecated: image(2), HintIconDataDeprecated: image(3)}, byte(1), true, false},
+		{"deprecated ones", Hints{HintImageDataDeprecated: image(2), HintIconDataDeprecated: image(3)}, byte(2), true, false},
+		{"not a struct", Hints{HintImageData: dbus.MakeVariant("image")}, nil, true, true},
+		{"short data", Hints{HintImageData: dbus.MakeVariant([]interface{}{int32(2), int32(2), int32(6), false, int32(8), int32(3), []byte{1}})}, nil, true, true},
+	}, func(hints Hints) (interface{}, bool, error) {
+		image, found, err := hints.ImageData()
+		if len(image.Data) == 0 {
+			return byte(0), found, err
+		}
+		return image.Data[0], found, err
+	})
+}
+
+func TestLegacyIconData(t *testing.T) {
+	for name, accessor := range map[string]func(Hints) (ImageData, bool, error){"LegacyIconData": Hints.LegacyIconData, "IconData": Hints.IconData} {
+		checkHint(t, name, []hintCase{
+			{"missing", Hints{}, byte(0), false, false},
+			{"icon_data", Hints{HintIconDataDeprecated: image(3)}, byte(3), true, false},
+			{"along image-data", Hints{HintImageData: image(1), HintIconDataDeprecated: image(3)}, byte(3), true, false},
+			{"icon-data", Hints{HintIconData: image(4)}, byte(0), false, false},
+			{"not a struct", Hints{HintIconDataDeprecated: dbus.MakeVariant("icon")}, nil, true, true},
+		}, func(hints Hints) (interface{}, bool, error) {
+			image, found, err := accessor(hints)
+			if len(image.Data) == 0 {
+				return byte(0), found, err
+			}
+			return image.Data[0], found, err
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Hints{HintUrgency: dbus.MakeVariant(byte(1)), HintImageData: image(1), HintX: dbus.MakeVariant(int32(10))}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	invalid := Hints{HintUrgency: dbus.MakeVariant(byte(1)), HintTransient: dbus.MakeVariant("yes")}
+	if err, ok := invalid.Validate().(*HintError); !ok || err.Key != HintTransient {
+		t.Fatalf("expected an error on %s, got %v", HintTransient, err)
+	}
+}
@@ -1,14 +1,47 @@
 package schema
 
-import "github.com/godbus/dbus"
+import "fmt"
 
 // Reason codes
 const (
 	Expired   = 1
 	Dismissed = 2
 	Closed    = 3
+	Undefined = 4
 )
 
+// Urgency levels
+const (
+	Low      Urgency = 0
+	Normal   Urgency = 1
+	Critical Urgency = 2
+)
+
+// Urgency is the urgency level of a notification
+type Urgency byte
+
+// String returns the lowercase name of the urgency level
+func (urgency Urgency) String() string {
+	switch urgency {
+	case Low:
+		return "low"
+	case Critical:
+		return "critical"
+	default:
+		return "normal"
+	}
+}
+
+// ParseUrgency reads the name of an urgency level, as returned by String
+func ParseUrgency(name string) (Urgency, error) {
+	for _, urgency := range []Urgency{Low, Normal, Critical} {
+		if urgency.String() == name {
+			return urgency, nil
+		}
+	}
+	return Normal, fmt.Errorf("%q is not one of low, normal or critical", name)
+}
+
 // Notification ...
 type Notification struct {
 	ID            uint32
@@ -18,8 +51,15 @@ type Notification struct {
 	Summary       string
 	Body          string
 	Actions       []interface{}
-	Hints         map[string]dbus.Variant
+	Hints         Hints
 	ExpireTimeout int32
+	Urgency       Urgency
+}
+
+// Action is a pair of action key and the label displayed to the user
+type Action struct {
+	Key   string
+	Label string
 }
 
 // ServerInformation ...
@@ -30,6 +70,14 @@ type ServerInformation struct {
 	SpecVersion string
 }
 
+// Suppression counts the notifications of an app or a sender that went over the rate limits
+type Suppression struct {
+	// Kind is either "app" or "sender"
+	Kind  string
+	Name  string
+	Count uint32
+}
+
 // ActionInvoked ...
 type ActionInvoked struct {
 	ID        uint32
@@ -53,53 +101,63 @@ type ImageData struct {
 	Data          []byte
 }
 
-// ImageData reads the image bytes from the Hints
-func (notification *Notification) ImageData() (ImageData, bool) {
-	return notification.loadRawImage("image-data")
-}
+// ActionPairs returns the actions as key and label pairs
+func (notification *Notification) ActionPairs() ([]Action, error) {
+	if len(notification.Actions)%2 != 0 {
+		return nil, fmt.Errorf("actions must be key and label pairs, got %d elements", len(notification.Actions))
+	}
 
-// IconData reads the image bytes from the Hints
-func (notification *Notification) IconData() (ImageData, bool) {
-	return notification.loadRawImage("icon-data")
+	var actions []Action
+	for i := 0; i < len(notification.Actions); i += 2 {
+		key, ok := notification.Actions[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("action key at %d is not a string: %v", i, notification.Actions[i])
+		}
+		label, ok := notification.Actions[i+1].(string)
+		if !ok {
+			return nil, fmt.Errorf("action label at %d is not a string: %v", i+1, notification.Actions[i+1])
+		}
+		actions = append(actions, Action{Key: key, Label: label})
+	}
+	return actions, nil
 }
 
-func (notification *Notification) loadRawImage(key string) (ImageData, bool) {
-	hints := notification.Hints
-	variant, found := hints[key]
-	if !found {
-		return ImageData{}, found
+// Validate checks the actions and hints of the notification
+func (notification *Notification) Validate() error {
+	if _, err := notification.ActionPairs(); err != nil {
+		return err
 	}
+	return notification.Hints.Validate()
+}
 
-	var imageData []interface{}
-	err := dbus.Store([]interface{}{variant}, &imageData)
-	if err != nil {
-		panic(err)
-	}
+// ImageData reads the image bytes from the Hints. Malformed images are reported as not found
+func (notification *Notification) ImageData() (ImageData, bool) {
+	image, found, err := notification.Hints.ImageData()
+	return image, found && err == nil
+}
 
-	image := ImageData{
-		Width:         imageData[0].(int32),
-		Height:        imageData[1].(int32),
-		RowStride:     imageData[2].(int32),
-		HasAlpha:      imageData[3].(bool),
-		BitsPerSample: imageData[4].(int32),
-		Channels:      imageData[5].(int32),
-		Data:          imageData[6].([]byte),
-	}
-	return image, true
+// LegacyIconData reads the image bytes of the deprecated icon_data hint, which comes after the image path and the
+// app icon. Malformed images are reported as not found
+func (notification *Notification) LegacyIconData() (ImageData, bool) {
+	image, found, err := notification.Hints.LegacyIconData()
+	return image, found && err == nil
 }
 
-// ImagePath returns the path for an image
-func (notification *Notification) ImagePath() (string, bool) {
-	hints := notification.Hints
-	variant, found := hints["image-path"]
-	if !found {
-		return "", found
-	}
+// IconData reads the image bytes of the deprecated icon_data hint. Malformed images are reported as not found.
+//
+// Deprecated: use LegacyIconData, only when there is no image data, image path nor app icon
+func (notification *Notification) IconData() (ImageData, bool) {
+	return notification.LegacyIconData()
+}
 
-	var imagePath string
-	err := dbus.Store([]interface{}{variant}, &imagePath)
-	if err != nil {
-		panic(err)
-	}
-	return imagePath, true
+// UrgencyHint reads the urgency from the Hints, defaulting to Normal
+func (notification *Notification) UrgencyHint() Urgency {
+	urgency, _, _ := notification.Hints.Urgency()
+	return urgency
+}
+
+// ImagePath returns the path for an image. Malformed paths are reported as not found
+func (notification *Notification) ImagePath() (string, bool) {
+	imagePath, found, err := notification.Hints.ImagePath()
+	return imagePath, found && err == nil
 }